@@ -0,0 +1,54 @@
+// cgroup-plugin is the reference out-of-process backend of the container
+// freezer. It finds the containers of pods through the CRI and freezes them
+// through cgroup v2. Run the daemon with RUNTIME_TYPE=plugin and
+// RUNTIME_ADDRESS set to its socket to use it.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"knative.dev/container-freezer/pkg/freeze/cgroup"
+	"knative.dev/container-freezer/pkg/freeze/plugin"
+	"knative.dev/pkg/signals"
+)
+
+func main() {
+	socket := flag.String("socket", "/var/run/container-freezer/plugin.sock", "unix socket to serve the plugin on")
+	criAddress := flag.String("cri-address", "/var/run/containerd/containerd.sock", "unix socket of the CRI runtime service")
+	cgroupRoot := flag.String("cgroup-root", cgroup.DefaultRoot, "where the cgroup v2 hierarchy is mounted")
+	flag.Parse()
+
+	ctx := signals.NewContext()
+
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, *criAddress, grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+	}))
+	if err != nil {
+		log.Fatalf("failed to dial the CRI at %s: %v", *criAddress, err)
+	}
+	defer conn.Close()
+
+	l, err := plugin.Listen(*socket)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *socket, err)
+	}
+
+	s := plugin.NewServer(&plugin.CgroupBackend{CRI: conn, Freezer: cgroup.NewFreezer(*cgroupRoot)})
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+
+	log.Printf("serving the cgroup plugin on %s", *socket)
+	if err := s.Serve(l); err != nil {
+		log.Fatal(err)
+	}
+}
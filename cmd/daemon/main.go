@@ -4,23 +4,145 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	authv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"knative.dev/container-freezer/pkg/admin"
 	"knative.dev/container-freezer/pkg/daemon"
+	"knative.dev/container-freezer/pkg/daemon/api"
+	"knative.dev/container-freezer/pkg/events"
 	"knative.dev/container-freezer/pkg/freeze"
+	"knative.dev/container-freezer/pkg/freeze/cgroup"
+	_ "knative.dev/container-freezer/pkg/freeze/plugin"
 	pkglogging "knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+	"knative.dev/pkg/signals"
 )
 
 type config struct {
 	RuntimeType string `split_words:"true" required:"true"`
+	// RuntimeAddress is the socket of the container runtime, by default the
+	// usual socket of the runtime
+	RuntimeAddress string `split_words:"true"`
+	// RuntimeHandlers routes pods to backends by the RuntimeClass handler of
+	// their sandbox, as handler=runtimeType[@address] separated by commas
+	RuntimeHandlers string `split_words:"true"`
+
+	// TokenValidation is either "tokenreview" or "jwks"
+	TokenValidation     string        `split_words:"true" default:"tokenreview"`
+	JWKSRefreshInterval time.Duration `envconfig:"JWKS_REFRESH_INTERVAL" default:"10m"`
+
+	// Debouncing of pauses. PauseDelay delays every pause, a resume within the
+	// delay cancels it. MinRunDuration delays pauses until a thawed pod has run
+	// for at least that long.
+	PauseDelay     time.Duration `split_words:"true"`
+	MinRunDuration time.Duration `split_words:"true"`
+
+	// AsyncFreeze answers pause requests straight away and freezes in the
+	// background. MaxConcurrentOperations limits how many pods are paused or
+	// resumed at once, zero means no limit.
+	AsyncFreeze             bool `split_words:"true"`
+	MaxConcurrentOperations int  `split_words:"true"`
+
+	// Timeouts of runtime calls, a pause which times out rolls the pod back
+	// and a resume which times out is retried. Zero means no timeout.
+	ListTimeout   time.Duration `split_words:"true" default:"10s"`
+	PauseTimeout  time.Duration `split_words:"true" default:"10s"`
+	ResumeTimeout time.Duration `split_words:"true" default:"10s"`
+	// CheckpointTimeout bounds checkpointing a container, which writes its
+	// memory to disk, and RestoreTimeout restoring it
+	CheckpointTimeout time.Duration `split_words:"true" default:"2m"`
+	RestoreTimeout    time.Duration `split_words:"true" default:"2m"`
+
+	// FreezeVerifyTimeout is how long a paused container has to reach the
+	// paused state before the pod is thawed again, zero disables the check.
+	// ProcRoot is where the host's processes are visible, used to report the
+	// processes holding up a stuck freeze.
+	FreezeVerifyTimeout time.Duration `split_words:"true" default:"5s"`
+	ProcRoot            string        `split_words:"true" default:"/proc"`
+
+	// AuditInterval is how often the state of containers is checked against
+	// what the freezer did to them, zero disables the audit
+	AuditInterval time.Duration `split_words:"true"`
+
+	// StateDir is where the daemon keeps what must survive its restarts, such
+	// as the original CPU resources of throttled containers
+	StateDir string `split_words:"true" default:"/var/lib/container-freezer"`
+
+	// Mode is how pods are stopped unless they select a mode with the
+	// container-freezer.knative.dev/mode annotation, "freeze" or "throttle"
+	Mode string `default:"freeze"`
+
+	// DryRun logs and records metrics for the pods which would be frozen and
+	// thawed, without touching their containers
+	DryRun bool `split_words:"true"`
+
+	// CheckpointAfter checkpoints pods frozen for that long to disk, zero
+	// disables checkpointing. Only the containerd runtime supports it.
+	CheckpointAfter time.Duration `split_words:"true"`
+
+	// MemoryReclaimAfter reclaims the memory of pods frozen for that long
+	// through cgroup v2, zero disables it. CgroupRoot is where the host's
+	// cgroup hierarchy is mounted in the daemon's container.
+	MemoryReclaimAfter time.Duration `split_words:"true"`
+	CgroupRoot         string        `split_words:"true" default:"/sys/fs/cgroup"`
+
+	// Token review caching, a zero TTL disables caching
+	TokenCacheTTL         time.Duration `split_words:"true" default:"1m"`
+	TokenCacheNegativeTTL time.Duration `split_words:"true" default:"5s"`
+
+	// Authorization of callers. RestrictToNode requires tokens with a node-name
+	// claim (Kubernetes 1.30+) matching NodeName, it is off by default so
+	// that older clusters keep working.
+	NodeName               string   `split_words:"true"`
+	RestrictToNode         bool     `split_words:"true"`
+	AllowedNamespaces      []string `split_words:"true"`
+	AllowedServiceAccounts []string `split_words:"true"`
+
+	// SubjectAccessReview additionally requires callers to have RBAC access to
+	// the pause/resume verbs on pods/freeze.
+	SubjectAccessReview        bool          `split_words:"true"`
+	SubjectAccessReviewTTL     time.Duration `split_words:"true" default:"1m"`
+	SubjectAccessReviewDenyTTL time.Duration `split_words:"true" default:"10s"`
+
+	// TLS for the listener, plain HTTP is served if no certificate is set.
+	// If a client CA is set callers must present a certificate signed by it.
+	TLSCertFile       string        `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `envconfig:"TLS_KEY_FILE"`
+	TLSClientCAFile   string        `envconfig:"TLS_CLIENT_CA_FILE"`
+	TLSReloadInterval time.Duration `envconfig:"TLS_RELOAD_INTERVAL" default:"1m"`
+
+	// GRPCAddress is the host:port the gRPC API is served on alongside the
+	// HTTP endpoint, with the same TLS. It is disabled if no address is set.
+	GRPCAddress string `envconfig:"GRPC_ADDRESS"`
+
+	// Admin API for operators, served on a unix socket (unix:///path) or a
+	// loopback host:port. It is disabled if no address is set. If a token file
+	// is set requests must carry its contents as a bearer token.
+	AdminAddress   string `split_words:"true"`
+	AdminTokenFile string `split_words:"true"`
+
+	// CloudEvents are sent to the sink on every freeze, thaw and failure if
+	// it is set. With NodeName set events also name the pod and its revision.
+	CloudEventsSink    string `envconfig:"CLOUDEVENTS_SINK"`
+	CloudEventsRetries int    `envconfig:"CLOUDEVENTS_RETRIES" default:"5"`
+
+	// Metrics configuration
+	MetricsBackendDestination string `split_words:"true" default:"prometheus"`
+	MetricsPrometheusPort     int    `split_words:"true" default:"9090"`
 
 	// Logging configuration
 	FreezerLoggingConfig string `split_words:"true"`
@@ -35,9 +157,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx := signals.NewContext()
 	logger, _ := pkglogging.NewLogger(env.FreezerLoggingConfig, env.FreezerLoggingLevel)
 	runtimeType := env.RuntimeType
 
+	if err := metrics.UpdateExporter(ctx, metrics.ExporterOptions{
+		Domain:         "knative.dev/container-freezer",
+		Component:      "freezer",
+		ConfigMap:      map[string]string{metrics.BackendDestinationKey: env.MetricsBackendDestination},
+		PrometheusPort: env.MetricsPrometheusPort,
+	}, logger); err != nil {
+		log.Fatal(err)
+	}
+
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		log.Fatal(err)
@@ -48,26 +180,224 @@ func main() {
 		log.Fatal(err)
 	}
 
-	var freezeThaw daemon.FreezeThawer
-	freezeThaw, err = freeze.NewCRIProvider(runtimeType)
+	opts := []freeze.Option{
+		freeze.WithLogger(logger),
+		freeze.WithRuntimeAddress(env.RuntimeAddress),
+		freeze.WithStateDir(env.StateDir),
+		freeze.WithPauseDelay(env.PauseDelay),
+		freeze.WithMinRunDuration(env.MinRunDuration),
+		freeze.WithMaxConcurrentOperations(env.MaxConcurrentOperations),
+		freeze.WithTimeouts(freeze.Timeouts{
+			List:       env.ListTimeout,
+			Pause:      env.PauseTimeout,
+			Resume:     env.ResumeTimeout,
+			Checkpoint: env.CheckpointTimeout,
+			Restore:    env.RestoreTimeout,
+		}),
+		freeze.WithFreezeVerification(env.FreezeVerifyTimeout, &cgroup.Freezer{Root: env.CgroupRoot, ProcRoot: env.ProcRoot}),
+		freeze.WithCheckpointAfter(env.CheckpointAfter),
+		freeze.WithMode(env.Mode),
+	}
+	if env.RuntimeHandlers != "" {
+		handlers, err := freeze.ParseRuntimeHandlers(env.RuntimeHandlers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, freeze.WithRuntimeHandlers(handlers...))
+	}
+	if env.DryRun {
+		opts = append(opts, freeze.WithDryRun())
+	}
+	if env.MemoryReclaimAfter > 0 {
+		opts = append(opts, freeze.WithMemoryReclaim(env.MemoryReclaimAfter, cgroup.NewReclaimer(env.CgroupRoot)))
+	}
+	// The gRPC server is wired up once the runtime exists, but needs to hear
+	// about its transitions and other changes of pods from the start
+	var grpcServer *daemon.GRPCServer
+	var onTransition []freeze.TransitionFunc
+	if env.GRPCAddress != "" {
+		grpcServer = &daemon.GRPCServer{}
+		onTransition = append(onTransition, func(t freeze.Transition) { grpcServer.Notify(t.PodUID) })
+		opts = append(opts, freeze.WithChangeFunc(grpcServer.Notify))
+	}
+	if env.CloudEventsSink != "" {
+		var resolver events.PodResolver
+		if env.NodeName != "" {
+			resolver = &events.NodePodResolver{Client: clientset, NodeName: env.NodeName}
+		}
+		emitter := events.NewEmitter(env.CloudEventsSink, "/apis/container-freezer/nodes/"+env.NodeName, resolver, logger)
+		emitter.Retries = env.CloudEventsRetries
+		go emitter.Run(ctx)
+		onTransition = append(onTransition, emitter.Emit)
+	}
+	if len(onTransition) > 0 {
+		opts = append(opts, freeze.WithTransitionFunc(func(t freeze.Transition) {
+			for _, fn := range onTransition {
+				fn(t)
+			}
+		}))
+	}
+
+	freezeThaw, err := freeze.NewCRIProvider(runtimeType, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	http.ListenAndServe(":8080", &daemon.Handler{
-		Freezer: freezeThaw,
-		Thawer:  freezeThaw,
-		Logger:  logger,
-		Validator: daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+	if env.AuditInterval > 0 {
+		go freezeThaw.RunAudit(ctx, env.AuditInterval)
+	}
+
+	if env.AdminAddress != "" {
+		if err := serveAdmin(env, freezeThaw, logger); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	validator, err := newValidator(env, config, clientset)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	claims := &daemon.ClaimsAuthorizer{
+		Namespaces:      env.AllowedNamespaces,
+		ServiceAccounts: env.AllowedServiceAccounts,
+	}
+	if env.RestrictToNode {
+		if env.NodeName == "" {
+			log.Fatal("NODE_NAME must be set when RESTRICT_TO_NODE is enabled")
+		}
+		claims.NodeName = env.NodeName
+	}
+	authorizer := daemon.Authorizers{claims}
+	// Only SubjectAccessReviews decide who may act on other pods than their own
+	var pods daemon.PodLookup
+	if env.SubjectAccessReview {
+		authorizer = append(authorizer, daemon.NewSubjectAccessReviewAuthorizer(clientset, env.SubjectAccessReviewTTL, env.SubjectAccessReviewDenyTTL))
+		if env.NodeName != "" {
+			pods = &daemon.NodePodLookup{Client: clientset, NodeName: env.NodeName}
+		}
+	}
+
+	server := &http.Server{
+		Addr: ":8080",
+		Handler: &daemon.Handler{
+			Freezer:     freezeThaw,
+			Thawer:      freezeThaw,
+			Logger:      logger,
+			Validator:   validator,
+			Authorizer:  authorizer,
+			Pods:        pods,
+			AsyncFreeze: env.AsyncFreeze,
+		},
+	}
+
+	var reloader *daemon.CertificateReloader
+	if env.TLSCertFile != "" {
+		reloader, err = daemon.NewCertificateReloader(env.TLSCertFile, env.TLSKeyFile, env.TLSClientCAFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go reloader.Watch(ctx, env.TLSReloadInterval, logger)
+	}
+
+	if grpcServer != nil {
+		grpcServer.Validator = validator
+		grpcServer.Authorizer = authorizer
+		grpcServer.Pods = pods
+		grpcServer.Freezer = freezeThaw
+		grpcServer.Thawer = freezeThaw
+		grpcServer.Reporter = daemon.StatusReporterFunc(func(podUID string) (daemon.PodStatus, bool) {
+			pod, ok := freezeThaw.Pod(podUID)
+			return daemon.PodStatus{
+				Throttled:  pod.Mode == freeze.ModeThrottle,
+				Containers: pod.Containers,
+				FrozenAt:   pod.FrozenAt,
+			}, ok
+		})
+		grpcServer.Logger = logger
+		grpcServer.AsyncFreeze = env.AsyncFreeze
+		grpcServer.ErrorCode = freeze.GRPCCode
+		if err := serveGRPC(env, grpcServer, reloader, logger); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if reloader == nil {
+		log.Fatal(server.ListenAndServe())
+	}
+	server.TLSConfig = reloader.TLSConfig()
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+func serveGRPC(env config, server *daemon.GRPCServer, reloader *daemon.CertificateReloader, logger *zap.SugaredLogger) error {
+	var opts []grpc.ServerOption
+	if reloader != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.TLSConfig("h2"))))
+	}
+	s := grpc.NewServer(opts...)
+	api.RegisterFreezerServer(s, server)
+
+	l, err := net.Listen("tcp", env.GRPCAddress)
+	if err != nil {
+		return err
+	}
+	go func() {
+		logger.Infof("serving gRPC API on %s", env.GRPCAddress)
+		if err := s.Serve(l); err != nil {
+			logger.Errorf("gRPC API stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func serveAdmin(env config, runtime admin.Runtime, logger *zap.SugaredLogger) error {
+	var token string
+	if env.AdminTokenFile != "" {
+		b, err := os.ReadFile(env.AdminTokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	l, err := admin.Listen(env.AdminAddress)
+	if err != nil {
+		return err
+	}
+	go func() {
+		logger.Infof("serving admin API on %s", env.AdminAddress)
+		if err := http.Serve(l, &admin.Handler{Runtime: runtime, Token: token, Logger: logger}); err != nil {
+			logger.Errorf("admin API stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// The projected token only gives the right to pause/resume
+const tokenAudience = "concurrency-state-hook"
+
+func newValidator(env config, cfg *rest.Config, clientset kubernetes.Interface) (daemon.TokenValidator, error) {
+	switch env.TokenValidation {
+	case "jwks":
+		client, err := rest.HTTPClientFor(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return daemon.NewJWKSValidator(client, cfg.Host, []string{tokenAudience}, env.JWKSRefreshInterval), nil
+	case "tokenreview":
+		var validator daemon.TokenValidator = daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
 			return clientset.AuthenticationV1().TokenReviews().Create(ctx, &authv1.TokenReview{
 				Spec: authv1.TokenReviewSpec{
-					Token: token,
-					Audiences: []string{
-						// The projected token only gives the right to pause/resume
-						"concurrency-state-hook",
-					},
+					Token:     token,
+					Audiences: []string{tokenAudience},
 				},
 			}, metav1.CreateOptions{})
-		}),
-	})
+		})
+		if env.TokenCacheTTL > 0 {
+			validator = daemon.NewCachingValidator(validator, env.TokenCacheTTL, env.TokenCacheNegativeTTL)
+		}
+		return validator, nil
+	default:
+		return nil, fmt.Errorf("unrecognised tokenValidation:%s", env.TokenValidation)
+	}
 }
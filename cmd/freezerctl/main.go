@@ -0,0 +1,272 @@
+// freezerctl inspects and overrides the container freezer on a node, either
+// through the daemon's admin API or directly through the container runtime.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"knative.dev/container-freezer/pkg/admin"
+	"knative.dev/container-freezer/pkg/freeze"
+	_ "knative.dev/container-freezer/pkg/freeze/plugin"
+)
+
+const usage = `Usage: freezerctl [flags] <command>
+
+Commands:
+  list            list frozen pods
+  status <pod>    show whether a pod is frozen and its recent errors
+  freeze <pod>    freeze a pod now
+  thaw <pod>      thaw a pod
+  thaw --all      thaw every frozen pod
+
+A pod is either a UID or namespace/name, which is resolved through kubeconfig.
+
+Flags:
+`
+
+type options struct {
+	address    string
+	tokenFile  string
+	runtime    string
+	kubeconfig string
+	timeout    time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.address, "address", envOr("FREEZERCTL_ADDRESS", "unix:///var/run/container-freezer/admin.sock"), "address of the daemon's admin API")
+	flag.StringVar(&opts.tokenFile, "token-file", os.Getenv("FREEZERCTL_TOKEN_FILE"), "file holding the admin API token")
+	flag.StringVar(&opts.runtime, "runtime", "", "talk to the container runtime directly instead of the daemon, one of "+strings.Join(freeze.Backends(), ", "))
+	flag.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig used to resolve namespace/name, defaults to the usual locations")
+	flag.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of the command")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(opts, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("no command given")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	b, err := newBackend(opts)
+	if err != nil {
+		return err
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		return list(ctx, b)
+	case "status":
+		uid, err := podArg(ctx, opts, args)
+		if err != nil {
+			return err
+		}
+		return status(ctx, b, uid)
+	case "freeze":
+		uid, err := podArg(ctx, opts, args)
+		if err != nil {
+			return err
+		}
+		return b.Freeze(ctx, uid)
+	case "thaw":
+		fs := flag.NewFlagSet("thaw", flag.ContinueOnError)
+		all := fs.Bool("all", false, "thaw every frozen pod")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *all {
+			if fs.NArg() != 0 {
+				return errors.New("thaw --all takes no pod")
+			}
+			return b.ThawAll(ctx)
+		}
+		uid, err := podArg(ctx, opts, fs.Args())
+		if err != nil {
+			return err
+		}
+		return b.Thaw(ctx, uid)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func list(ctx context.Context, b backend) error {
+	pods, err := b.Pods(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POD UID\tMODE\tCONTAINERS\tFROZEN FOR\tRECLAIMED")
+	for _, p := range pods {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", p.PodUID, p.Mode, len(p.Containers), p.FrozenFor, formatBytes(p.ReclaimedBytes))
+	}
+	return w.Flush()
+}
+
+func status(ctx context.Context, b backend, uid string) error {
+	pods, err := b.Pods(ctx)
+	if err != nil {
+		return err
+	}
+	errs, err := b.Errors(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pod:    %s\n", uid)
+	frozen := false
+	for _, p := range pods {
+		if p.PodUID == uid {
+			frozen = true
+			state := "frozen"
+			if p.Mode == freeze.ModeThrottle {
+				state = "throttled"
+			}
+			fmt.Printf("State:  %s for %s since %s\n", state, p.FrozenFor, p.FrozenAt.Format(time.RFC3339))
+			fmt.Printf("Paused: %s\n", strings.Join(p.Containers, ", "))
+			if p.ReclaimedBytes > 0 {
+				fmt.Printf("Memory: %s reclaimed\n", formatBytes(p.ReclaimedBytes))
+			}
+		}
+	}
+	if !frozen {
+		fmt.Println("State:  running")
+	}
+	for _, e := range errs {
+		if e.PodUID == uid {
+			fmt.Printf("Error:  %s %s failed: %s\n", e.Time.Format(time.RFC3339), e.Action, e.Error)
+		}
+	}
+	return nil
+}
+
+// podArg returns the UID of the single pod named in args, resolving
+// namespace/name through kubeconfig
+func podArg(ctx context.Context, opts options, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("expected a single pod")
+	}
+	namespace, name, ok := strings.Cut(args[0], "/")
+	if !ok {
+		return args[0], nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.kubeconfig
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(pod.UID), nil
+}
+
+// formatBytes formats n in binary units, e.g. "12.5Mi"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ci", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// backend is what freezerctl talks to
+type backend interface {
+	Pods(ctx context.Context) ([]admin.Pod, error)
+	Errors(ctx context.Context) ([]admin.Error, error)
+	Freeze(ctx context.Context, podUID string) error
+	Thaw(ctx context.Context, podUID string) error
+	ThawAll(ctx context.Context) error
+}
+
+func newBackend(opts options) (backend, error) {
+	if opts.runtime != "" {
+		cri, err := freeze.NewCRIProvider(opts.runtime)
+		if err != nil {
+			return nil, err
+		}
+		return runtimeBackend{cri}, nil
+	}
+
+	var token string
+	if opts.tokenFile != "" {
+		b, err := os.ReadFile(opts.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	return admin.NewClient(opts.address, token), nil
+}
+
+// errNeedsDaemon is returned for commands which need the state kept by the daemon
+var errNeedsDaemon = errors.New("only the daemon knows which pods are frozen, this command needs its admin API rather than --runtime")
+
+// runtimeBackend freezes and thaws pods through the container runtime, for
+// when the daemon is down. It does not know which pods the daemon froze.
+type runtimeBackend struct {
+	cri *freeze.ContainerRuntimeImpl
+}
+
+func (r runtimeBackend) Pods(ctx context.Context) ([]admin.Pod, error) {
+	return nil, errNeedsDaemon
+}
+
+func (r runtimeBackend) Errors(ctx context.Context) ([]admin.Error, error) {
+	return nil, errNeedsDaemon
+}
+
+func (r runtimeBackend) Freeze(ctx context.Context, podUID string) error {
+	return r.cri.Freeze(ctx, podUID)
+}
+
+func (r runtimeBackend) Thaw(ctx context.Context, podUID string) error {
+	return r.cri.Thaw(ctx, podUID)
+}
+
+func (r runtimeBackend) ThawAll(ctx context.Context) error {
+	return errNeedsDaemon
+}
@@ -0,0 +1,210 @@
+// Package admin serves a node-local API for operators to inspect and override
+// the freezer. It is meant to be served on a unix socket or a loopback
+// address, separately from the endpoint queue-proxy calls.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"knative.dev/container-freezer/pkg/freeze"
+)
+
+// Runtime is the part of the freezer the admin API drives
+type Runtime interface {
+	Frozen() []freeze.FrozenPod
+	RecentErrors() []freeze.OperationError
+	ForceFreeze(ctx context.Context, podName string) error
+	Thaw(ctx context.Context, podName string) error
+	ThawAll(ctx context.Context) error
+}
+
+// Pod describes a frozen pod
+type Pod struct {
+	PodUID     string    `json:"podUID"`
+	Containers []string  `json:"containers"`
+	FrozenAt   time.Time `json:"frozenAt"`
+	// FrozenFor is how long the pod has been frozen, e.g. "1m30s"
+	FrozenFor string `json:"frozenFor"`
+	// Mode is "freeze" or "throttle"
+	Mode string `json:"mode"`
+	// ReclaimedBytes is how much memory was reclaimed since the pod was frozen
+	ReclaimedBytes int64 `json:"reclaimedBytes,omitempty"`
+}
+
+// Error describes a failed operation on a pod
+type Error struct {
+	Time   time.Time `json:"time"`
+	PodUID string    `json:"podUID"`
+	Action string    `json:"action"`
+	Error  string    `json:"error"`
+}
+
+// Result is returned by the freeze and thaw endpoints
+type Result struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Handler serves the admin API:
+//
+//	GET  /pods               list frozen pods
+//	POST /pods/{uid}/freeze  freeze a pod now
+//	POST /pods/{uid}/thaw    thaw a pod
+//	POST /thaw               thaw every frozen pod
+//	GET  /errors             recent failed operations
+type Handler struct {
+	Runtime Runtime
+	// Token is optional, if set requests must carry it as a bearer token
+	Token  string
+	Logger *zap.SugaredLogger
+
+	now func() time.Time
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Token != "" && !h.authenticated(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "pods":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.listPods(w)
+	case path == "errors":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		h.listErrors(w)
+	case path == "thaw":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		h.Logger.Info("admin request received, thawing all pods")
+		h.writeResult(w, h.Runtime.ThawAll(r.Context()))
+	case strings.HasPrefix(path, "pods/"):
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		parts := strings.Split(path, "/")
+		if len(parts) != 3 || parts[1] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		h.podAction(w, r, parts[1], parts[2])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *Handler) podAction(w http.ResponseWriter, r *http.Request, podUID, action string) {
+	switch action {
+	case freeze.ActionFreeze:
+		h.Logger.Infof("admin request received, freezing pod: %s", podUID)
+		h.writeResult(w, h.Runtime.ForceFreeze(r.Context(), podUID))
+	case freeze.ActionThaw:
+		h.Logger.Infof("admin request received, thawing pod: %s", podUID)
+		h.writeResult(w, h.Runtime.Thaw(r.Context(), podUID))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *Handler) listPods(w http.ResponseWriter) {
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+	pods := []Pod{}
+	for _, p := range h.Runtime.Frozen() {
+		pods = append(pods, Pod{
+			PodUID:         p.PodUID,
+			Containers:     p.Containers,
+			FrozenAt:       p.FrozenAt,
+			FrozenFor:      now.Sub(p.FrozenAt).Round(time.Second).String(),
+			Mode:           p.Mode,
+			ReclaimedBytes: p.ReclaimedBytes,
+		})
+	}
+	writeJSON(w, http.StatusOK, pods)
+}
+
+func (h *Handler) listErrors(w http.ResponseWriter) {
+	errs := []Error{}
+	for _, e := range h.Runtime.RecentErrors() {
+		errs = append(errs, Error{Time: e.Time, PodUID: e.PodUID, Action: e.Action, Error: e.Error})
+	}
+	writeJSON(w, http.StatusOK, errs)
+}
+
+func (h *Handler) writeResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		h.Logger.Errorf("admin request failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, Result{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Result{})
+}
+
+func (h *Handler) authenticated(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Listen listens on address, which is either unix:///path/to/socket or a
+// host:port on a loopback address. A stale socket file is replaced, and a new
+// socket is only accessible by its owner.
+func Listen(address string) (net.Listener, error) {
+	if path := strings.TrimPrefix(address, "unix://"); path != address {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("admin address %s is not a loopback address", address)
+		}
+	}
+	return net.Listen("tcp", address)
+}
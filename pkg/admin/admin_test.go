@@ -0,0 +1,181 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	ltesting "knative.dev/pkg/logging/testing"
+
+	"knative.dev/container-freezer/pkg/freeze"
+)
+
+type fakeRuntime struct {
+	frozen []freeze.FrozenPod
+	errs   []freeze.OperationError
+	err    error
+	calls  []string
+}
+
+func (f *fakeRuntime) Frozen() []freeze.FrozenPod            { return f.frozen }
+func (f *fakeRuntime) RecentErrors() []freeze.OperationError { return f.errs }
+
+func (f *fakeRuntime) ForceFreeze(ctx context.Context, podName string) error {
+	f.calls = append(f.calls, "freeze "+podName)
+	return f.err
+}
+
+func (f *fakeRuntime) Thaw(ctx context.Context, podName string) error {
+	f.calls = append(f.calls, "thaw "+podName)
+	return f.err
+}
+
+func (f *fakeRuntime) ThawAll(ctx context.Context) error {
+	f.calls = append(f.calls, "thaw all")
+	return f.err
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		token     string
+		err       error
+		wantCode  int
+		wantCalls []string
+	}{{
+		name:      "freeze pod",
+		method:    http.MethodPost,
+		path:      "/pods/123/freeze",
+		wantCode:  http.StatusOK,
+		wantCalls: []string{"freeze 123"},
+	}, {
+		name:      "thaw pod",
+		method:    http.MethodPost,
+		path:      "/pods/123/thaw",
+		wantCode:  http.StatusOK,
+		wantCalls: []string{"thaw 123"},
+	}, {
+		name:      "thaw all",
+		method:    http.MethodPost,
+		path:      "/thaw",
+		wantCode:  http.StatusOK,
+		wantCalls: []string{"thaw all"},
+	}, {
+		name:      "thaw fails",
+		method:    http.MethodPost,
+		path:      "/pods/123/thaw",
+		err:       errors.New("boom"),
+		wantCode:  http.StatusInternalServerError,
+		wantCalls: []string{"thaw 123"},
+	}, {
+		name:     "unknown action",
+		method:   http.MethodPost,
+		path:     "/pods/123/restart",
+		wantCode: http.StatusNotFound,
+	}, {
+		name:     "wrong method",
+		method:   http.MethodGet,
+		path:     "/pods/123/thaw",
+		wantCode: http.StatusMethodNotAllowed,
+	}, {
+		name:      "valid token",
+		method:    http.MethodPost,
+		path:      "/thaw",
+		token:     "secret",
+		wantCode:  http.StatusOK,
+		wantCalls: []string{"thaw all"},
+	}, {
+		name:     "wrong token",
+		method:   http.MethodPost,
+		path:     "/thaw",
+		token:    "wrong",
+		wantCode: http.StatusUnauthorized,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &fakeRuntime{err: c.err}
+			h := &Handler{Runtime: fake, Logger: ltesting.TestLogger(t)}
+			if c.token != "" {
+				h.Token = "secret"
+			}
+
+			req := httptest.NewRequest(c.method, c.path, nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != c.wantCode {
+				t.Errorf("expected status %d but got %d", c.wantCode, rec.Code)
+			}
+			if !reflect.DeepEqual(fake.calls, c.wantCalls) {
+				t.Errorf("expected calls %v but got %v", c.wantCalls, fake.calls)
+			}
+		})
+	}
+}
+
+func TestHandlerListPods(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := &fakeRuntime{frozen: []freeze.FrozenPod{{
+		PodUID:     "123",
+		Containers: []string{"a", "b"},
+		FrozenAt:   now.Add(-90 * time.Second),
+	}}}
+	h := &Handler{Runtime: fake, Logger: ltesting.TestLogger(t), now: func() time.Time { return now }}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pods", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rec.Code)
+	}
+
+	var pods []Pod
+	if err := json.NewDecoder(rec.Body).Decode(&pods); err != nil {
+		t.Fatal(err)
+	}
+	want := []Pod{{
+		PodUID:     "123",
+		Containers: []string{"a", "b"},
+		FrozenAt:   now.Add(-90 * time.Second),
+		FrozenFor:  "1m30s",
+	}}
+	if !reflect.DeepEqual(pods, want) {
+		t.Errorf("expected %+v but got %+v", want, pods)
+	}
+}
+
+func TestListen(t *testing.T) {
+	if _, err := Listen("0.0.0.0:0"); err == nil {
+		t.Error("expected listening on a non-loopback address to fail")
+	}
+
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected listening on a loopback address to succeed but failed: %v", err)
+	}
+	l.Close()
+
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	for i := 0; i < 2; i++ {
+		// The second listen replaces the socket file left behind by the first
+		l, err := Listen("unix://" + path)
+		if err != nil {
+			t.Fatalf("expected listening on a unix socket to succeed but failed: %v", err)
+		}
+		if ul, ok := l.(interface{ SetUnlinkOnClose(bool) }); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		l.Close()
+	}
+}
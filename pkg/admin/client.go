@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the admin API of a daemon
+type Client struct {
+	HTTPClient *http.Client
+	// BaseURL is the URL the API paths are appended to
+	BaseURL string
+	// Token is sent as a bearer token if set
+	Token string
+}
+
+// NewClient returns a client for the admin API listening on address, in the
+// form accepted by Listen
+func NewClient(address, token string) *Client {
+	if path := strings.TrimPrefix(address, "unix://"); path != address {
+		return &Client{
+			HTTPClient: &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			}},
+			BaseURL: "http://localhost",
+			Token:   token,
+		}
+	}
+	return &Client{HTTPClient: http.DefaultClient, BaseURL: "http://" + address, Token: token}
+}
+
+// Pods returns the frozen pods
+func (c *Client) Pods(ctx context.Context) ([]Pod, error) {
+	var pods []Pod
+	return pods, c.do(ctx, http.MethodGet, "/pods", &pods)
+}
+
+// Errors returns the recent failed operations
+func (c *Client) Errors(ctx context.Context) ([]Error, error) {
+	var errs []Error
+	return errs, c.do(ctx, http.MethodGet, "/errors", &errs)
+}
+
+// Freeze freezes the pod straight away
+func (c *Client) Freeze(ctx context.Context, podUID string) error {
+	return c.do(ctx, http.MethodPost, "/pods/"+url.PathEscape(podUID)+"/freeze", nil)
+}
+
+// Thaw thaws the pod
+func (c *Client) Thaw(ctx context.Context, podUID string) error {
+	return c.do(ctx, http.MethodPost, "/pods/"+url.PathEscape(podUID)+"/thaw", nil)
+}
+
+// ThawAll thaws every frozen pod
+func (c *Client) ThawAll(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/thaw", nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result Result
+		if json.NewDecoder(resp.Body).Decode(&result) == nil && result.Error != "" {
+			return fmt.Errorf("%s %s: %s", method, path, result.Error)
+		}
+		return fmt.Errorf("%s %s: unexpected status %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ltesting "knative.dev/pkg/logging/testing"
+
+	"knative.dev/container-freezer/pkg/freeze"
+)
+
+func TestClient(t *testing.T) {
+	address := "unix://" + filepath.Join(t.TempDir(), "admin.sock")
+	l, err := Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := &fakeRuntime{
+		frozen: []freeze.FrozenPod{{PodUID: "123", Containers: []string{"a"}, FrozenAt: now}},
+		errs:   []freeze.OperationError{{Time: now, PodUID: "456", Action: freeze.ActionThaw, Error: "boom"}},
+	}
+	server := &http.Server{Handler: &Handler{Runtime: fake, Token: "secret", Logger: ltesting.TestLogger(t), now: func() time.Time { return now }}}
+	go server.Serve(l)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(address, "secret")
+
+	pods, err := client.Pods(ctx)
+	if err != nil {
+		t.Fatalf("expected listing pods to succeed but failed: %v", err)
+	}
+	if want := []Pod{{PodUID: "123", Containers: []string{"a"}, FrozenAt: now, FrozenFor: "0s"}}; !reflect.DeepEqual(pods, want) {
+		t.Errorf("expected pods %+v but got %+v", want, pods)
+	}
+
+	errs, err := client.Errors(ctx)
+	if err != nil {
+		t.Fatalf("expected listing errors to succeed but failed: %v", err)
+	}
+	if want := []Error{{Time: now, PodUID: "456", Action: freeze.ActionThaw, Error: "boom"}}; !reflect.DeepEqual(errs, want) {
+		t.Errorf("expected errors %+v but got %+v", want, errs)
+	}
+
+	if err := client.Freeze(ctx, "123"); err != nil {
+		t.Errorf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := client.ThawAll(ctx); err != nil {
+		t.Errorf("expected thaw all to succeed but failed: %v", err)
+	}
+	if want := []string{"freeze 123", "thaw all"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("expected calls %v but got %v", want, fake.calls)
+	}
+
+	fake.err = errors.New("boom")
+	if err := client.Thaw(ctx, "123"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the runtime error to be returned but got %v", err)
+	}
+
+	if err := NewClient(address, "wrong").ThawAll(ctx); err == nil {
+		t.Error("expected a request with the wrong token to fail")
+	}
+}
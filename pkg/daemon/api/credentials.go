@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// TokenMetadataKey is the metadata the daemon reads the token of the calling
+// pod from
+const TokenMetadataKey = "token"
+
+// Token passes a projected service account token with every call, for use
+// with grpc.WithPerRPCCredentials
+type Token string
+
+func (t Token) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{TokenMetadataKey: string(t)}, nil
+}
+
+// RequireTransportSecurity is false, the daemon may serve plain gRPC on the
+// node
+func (t Token) RequireTransportSecurity() bool {
+	return false
+}
+
+// TokenFile passes the token read from a file with every call. The file is
+// read on every call, since the kubelet rotates projected tokens.
+type TokenFile string
+
+func (f TokenFile) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{TokenMetadataKey: strings.TrimSpace(string(b))}, nil
+}
+
+// RequireTransportSecurity is false, the daemon may serve plain gRPC on the
+// node
+func (f TokenFile) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: freezer.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// FreezerClient is the client API for Freezer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FreezerClient interface {
+	// Freeze pauses the containers of the calling pod
+	Freeze(ctx context.Context, in *FreezeRequest, opts ...grpc.CallOption) (*FreezeResponse, error)
+	// Thaw resumes the containers of the calling pod
+	Thaw(ctx context.Context, in *ThawRequest, opts ...grpc.CallOption) (*ThawResponse, error)
+	// Status reports whether the calling pod is frozen
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*PodStatus, error)
+	// Watch sends the status of the calling pod, then again every time it is
+	// frozen or thawed
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Freezer_WatchClient, error)
+}
+
+type freezerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFreezerClient(cc grpc.ClientConnInterface) FreezerClient {
+	return &freezerClient{cc}
+}
+
+func (c *freezerClient) Freeze(ctx context.Context, in *FreezeRequest, opts ...grpc.CallOption) (*FreezeResponse, error) {
+	out := new(FreezeResponse)
+	err := c.cc.Invoke(ctx, "/container_freezer.daemon.v1.Freezer/Freeze", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *freezerClient) Thaw(ctx context.Context, in *ThawRequest, opts ...grpc.CallOption) (*ThawResponse, error) {
+	out := new(ThawResponse)
+	err := c.cc.Invoke(ctx, "/container_freezer.daemon.v1.Freezer/Thaw", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *freezerClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*PodStatus, error) {
+	out := new(PodStatus)
+	err := c.cc.Invoke(ctx, "/container_freezer.daemon.v1.Freezer/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *freezerClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Freezer_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Freezer_ServiceDesc.Streams[0], "/container_freezer.daemon.v1.Freezer/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &freezerWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Freezer_WatchClient interface {
+	Recv() (*PodStatus, error)
+	grpc.ClientStream
+}
+
+type freezerWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *freezerWatchClient) Recv() (*PodStatus, error) {
+	m := new(PodStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FreezerServer is the server API for Freezer service.
+// All implementations must embed UnimplementedFreezerServer
+// for forward compatibility
+type FreezerServer interface {
+	// Freeze pauses the containers of the calling pod
+	Freeze(context.Context, *FreezeRequest) (*FreezeResponse, error)
+	// Thaw resumes the containers of the calling pod
+	Thaw(context.Context, *ThawRequest) (*ThawResponse, error)
+	// Status reports whether the calling pod is frozen
+	Status(context.Context, *StatusRequest) (*PodStatus, error)
+	// Watch sends the status of the calling pod, then again every time it is
+	// frozen or thawed
+	Watch(*WatchRequest, Freezer_WatchServer) error
+	mustEmbedUnimplementedFreezerServer()
+}
+
+// UnimplementedFreezerServer must be embedded to have forward compatible implementations.
+type UnimplementedFreezerServer struct {
+}
+
+func (UnimplementedFreezerServer) Freeze(context.Context, *FreezeRequest) (*FreezeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Freeze not implemented")
+}
+func (UnimplementedFreezerServer) Thaw(context.Context, *ThawRequest) (*ThawResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Thaw not implemented")
+}
+func (UnimplementedFreezerServer) Status(context.Context, *StatusRequest) (*PodStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedFreezerServer) Watch(*WatchRequest, Freezer_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedFreezerServer) mustEmbedUnimplementedFreezerServer() {}
+
+// UnsafeFreezerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FreezerServer will
+// result in compilation errors.
+type UnsafeFreezerServer interface {
+	mustEmbedUnimplementedFreezerServer()
+}
+
+func RegisterFreezerServer(s grpc.ServiceRegistrar, srv FreezerServer) {
+	s.RegisterService(&Freezer_ServiceDesc, srv)
+}
+
+func _Freezer_Freeze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FreezeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FreezerServer).Freeze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/container_freezer.daemon.v1.Freezer/Freeze",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FreezerServer).Freeze(ctx, req.(*FreezeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Freezer_Thaw_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ThawRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FreezerServer).Thaw(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/container_freezer.daemon.v1.Freezer/Thaw",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FreezerServer).Thaw(ctx, req.(*ThawRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Freezer_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FreezerServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/container_freezer.daemon.v1.Freezer/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FreezerServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Freezer_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FreezerServer).Watch(m, &freezerWatchServer{stream})
+}
+
+type Freezer_WatchServer interface {
+	Send(*PodStatus) error
+	grpc.ServerStream
+}
+
+type freezerWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *freezerWatchServer) Send(m *PodStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Freezer_ServiceDesc is the grpc.ServiceDesc for Freezer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Freezer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "container_freezer.daemon.v1.Freezer",
+	HandlerType: (*FreezerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Freeze",
+			Handler:    _Freezer_Freeze_Handler,
+		},
+		{
+			MethodName: "Thaw",
+			Handler:    _Freezer_Thaw_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Freezer_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Freezer_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "freezer.proto",
+}
@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+const (
+	podUIDExtraKey   = "authentication.kubernetes.io/pod-uid"
+	podNameExtraKey  = "authentication.kubernetes.io/pod-name"
+	nodeNameExtraKey = "authentication.kubernetes.io/node-name"
+
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+)
+
+// Causes of rejected requests. Several causes share a status code, the cause
+// tells them apart.
+const (
+	CausePodUIDMissing            = "PodUIDMissing"
+	CauseNodeNameMissing          = "NodeNameMissing"
+	CauseOtherNode                = "OtherNode"
+	CauseNamespaceNotAllowed      = "NamespaceNotAllowed"
+	CauseServiceAccountNotAllowed = "ServiceAccountNotAllowed"
+	CauseNotServiceAccount        = "NotServiceAccount"
+	CauseAccessReviewDenied       = "AccessReviewDenied"
+	CauseTargetNotAllowed         = "TargetNotAllowed"
+	CauseTargetInvalid            = "TargetInvalid"
+	CausePodNotFound              = "PodNotFound"
+)
+
+// AuthorizationError is returned when a caller is not allowed to make a
+// request, Code is the HTTP status code the Handler responds with and Cause
+// says why.
+type AuthorizationError struct {
+	Code   int
+	Cause  string
+	Reason string
+}
+
+func (e *AuthorizationError) Error() string {
+	return e.Reason
+}
+
+func authzErrorf(code int, cause, format string, args ...interface{}) error {
+	return &AuthorizationError{Code: code, Cause: cause, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Caller is the pod making a request, as described by its authenticated token
+type Caller struct {
+	PodUID         string
+	PodName        string
+	NodeName       string
+	Namespace      string
+	ServiceAccount string
+	User           authv1.UserInfo
+	// Target is set when the request names a pod other than the caller's to
+	// act on. Authorizers which allow such requests must check it.
+	Target *PodRef
+}
+
+// TargetPodUID is the UID of the pod the request acts on, the target if one
+// was named and the caller's own pod otherwise
+func (c *Caller) TargetPodUID() string {
+	if c.Target != nil {
+		return c.Target.UID
+	}
+	return c.PodUID
+}
+
+// CallerFromUserInfo reads the identity of the calling pod from the user info
+// of a TokenReview. Tokens which are not bound to a pod are rejected.
+func CallerFromUserInfo(user authv1.UserInfo) (*Caller, error) {
+	podUID := firstExtra(user, podUIDExtraKey)
+	if podUID == "" {
+		return nil, authzErrorf(http.StatusBadRequest, CausePodUIDMissing, "token has no %s claim", podUIDExtraKey)
+	}
+
+	c := &Caller{
+		PodUID:   podUID,
+		PodName:  firstExtra(user, podNameExtraKey),
+		NodeName: firstExtra(user, nodeNameExtraKey),
+		User:     user,
+	}
+	if strings.HasPrefix(user.Username, serviceAccountUsernamePrefix) {
+		if parts := strings.SplitN(strings.TrimPrefix(user.Username, serviceAccountUsernamePrefix), ":", 2); len(parts) == 2 {
+			c.Namespace, c.ServiceAccount = parts[0], parts[1]
+		}
+	}
+	return c, nil
+}
+
+func firstExtra(user authv1.UserInfo, key string) string {
+	if v := user.Extra[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Authorizer decides whether a caller may perform an action on its pod
+type Authorizer interface {
+	Authorize(ctx context.Context, caller *Caller, action string) error
+}
+
+// AuthorizerFunc adapts a function to an Authorizer
+type AuthorizerFunc func(ctx context.Context, caller *Caller, action string) error
+
+func (fn AuthorizerFunc) Authorize(ctx context.Context, caller *Caller, action string) error {
+	return fn(ctx, caller, action)
+}
+
+// ClaimsAuthorizer authorizes callers based on the claims of their token.
+type ClaimsAuthorizer struct {
+	// NodeName is the node the daemon runs on. If set, callers must have a
+	// node-name claim and it must match.
+	NodeName string
+	// Namespaces, if not empty, are the only namespaces callers may be in
+	Namespaces []string
+	// ServiceAccounts, if not empty, are the only service accounts callers
+	// may use, either as "namespace/name" or as "name" in any namespace
+	ServiceAccounts []string
+}
+
+// Authorize returns an *AuthorizationError with a distinct cause for each reason a caller is rejected
+func (a *ClaimsAuthorizer) Authorize(_ context.Context, caller *Caller, _ string) error {
+	if a.NodeName != "" {
+		if caller.NodeName == "" {
+			return authzErrorf(http.StatusPreconditionFailed, CauseNodeNameMissing, "token has no %s claim", nodeNameExtraKey)
+		}
+		if caller.NodeName != a.NodeName {
+			return authzErrorf(http.StatusMisdirectedRequest, CauseOtherNode, "pod %s is on node %s, not %s", caller.PodUID, caller.NodeName, a.NodeName)
+		}
+	}
+
+	if len(a.Namespaces) > 0 && !contains(a.Namespaces, caller.Namespace) {
+		return authzErrorf(http.StatusForbidden, CauseNamespaceNotAllowed, "namespace %q is not allowed", caller.Namespace)
+	}
+
+	if len(a.ServiceAccounts) > 0 &&
+		!contains(a.ServiceAccounts, caller.ServiceAccount) &&
+		!contains(a.ServiceAccounts, caller.Namespace+"/"+caller.ServiceAccount) {
+		return authzErrorf(http.StatusForbidden, CauseServiceAccountNotAllowed, "service account %s/%s is not allowed", caller.Namespace, caller.ServiceAccount)
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"knative.dev/container-freezer/pkg/daemon"
+)
+
+func TestCallerFromUserInfo(t *testing.T) {
+	caller, err := daemon.CallerFromUserInfo(authv1.UserInfo{
+		Username: "system:serviceaccount:the-ns:the-sa",
+		Extra: map[string]authv1.ExtraValue{
+			"authentication.kubernetes.io/pod-uid":   {"the-pod-uid"},
+			"authentication.kubernetes.io/pod-name":  {"the-pod"},
+			"authentication.kubernetes.io/node-name": {"the-node"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+
+	want := []string{"the-pod-uid", "the-pod", "the-node", "the-ns", "the-sa"}
+	got := []string{caller.PodUID, caller.PodName, caller.NodeName, caller.Namespace, caller.ServiceAccount}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected caller %+v but got %+v", want, got)
+	}
+
+	_, err = daemon.CallerFromUserInfo(authv1.UserInfo{Username: "system:serviceaccount:the-ns:the-sa"})
+	var authzErr *daemon.AuthorizationError
+	if !errors.As(err, &authzErr) || authzErr.Code != 400 {
+		t.Errorf("expected an authorization error with code 400 but got %v", err)
+	}
+}
+
+func TestClaimsAuthorizer(t *testing.T) {
+	caller := func(node, namespace, sa string) *daemon.Caller {
+		return &daemon.Caller{PodUID: "the-pod-uid", NodeName: node, Namespace: namespace, ServiceAccount: sa}
+	}
+
+	tests := []struct {
+		name        string
+		authorizer  daemon.ClaimsAuthorizer
+		caller      *daemon.Caller
+		expectCode  int
+		expectCause string
+	}{{
+		name:       "no restrictions",
+		authorizer: daemon.ClaimsAuthorizer{},
+		caller:     caller("", "default", "default"),
+	}, {
+		name:       "same node",
+		authorizer: daemon.ClaimsAuthorizer{NodeName: "node-a"},
+		caller:     caller("node-a", "default", "default"),
+	}, {
+		name:        "no node claim",
+		authorizer:  daemon.ClaimsAuthorizer{NodeName: "node-a"},
+		caller:      caller("", "default", "default"),
+		expectCode:  412,
+		expectCause: daemon.CauseNodeNameMissing,
+	}, {
+		name:        "other node",
+		authorizer:  daemon.ClaimsAuthorizer{NodeName: "node-a"},
+		caller:      caller("node-b", "default", "default"),
+		expectCode:  421,
+		expectCause: daemon.CauseOtherNode,
+	}, {
+		name:       "namespace allowed",
+		authorizer: daemon.ClaimsAuthorizer{Namespaces: []string{"default", "apps"}},
+		caller:     caller("", "apps", "default"),
+	}, {
+		name:        "namespace not allowed",
+		authorizer:  daemon.ClaimsAuthorizer{Namespaces: []string{"default"}},
+		caller:      caller("", "kube-system", "default"),
+		expectCode:  403,
+		expectCause: daemon.CauseNamespaceNotAllowed,
+	}, {
+		name:       "service account allowed by name",
+		authorizer: daemon.ClaimsAuthorizer{ServiceAccounts: []string{"freezable"}},
+		caller:     caller("", "apps", "freezable"),
+	}, {
+		name:       "service account allowed by namespace and name",
+		authorizer: daemon.ClaimsAuthorizer{ServiceAccounts: []string{"apps/freezable"}},
+		caller:     caller("", "apps", "freezable"),
+	}, {
+		name:        "service account not allowed",
+		authorizer:  daemon.ClaimsAuthorizer{ServiceAccounts: []string{"apps/freezable"}},
+		caller:      caller("", "default", "freezable"),
+		expectCode:  403,
+		expectCause: daemon.CauseServiceAccountNotAllowed,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.authorizer.Authorize(context.Background(), test.caller, "pause")
+			if test.expectCode == 0 {
+				if err != nil {
+					t.Errorf("expected no error but got %v", err)
+				}
+				return
+			}
+
+			var authzErr *daemon.AuthorizationError
+			if !errors.As(err, &authzErr) {
+				t.Fatalf("expected an authorization error but got %v", err)
+			}
+			if authzErr.Code != test.expectCode {
+				t.Errorf("expected code %d but got %d", test.expectCode, authzErr.Code)
+			}
+			if authzErr.Cause != test.expectCause {
+				t.Errorf("expected cause %s but got %s", test.expectCause, authzErr.Cause)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+// maxCacheEntries bounds the number of entries kept before expired ones are swept.
+const maxCacheEntries = 4096
+
+type cacheEntry struct {
+	review  *authv1.TokenReview
+	expires time.Time
+}
+
+// CachingValidator is a TokenValidator which caches the results of another
+// TokenValidator, keyed by a hash of the token. Authenticated results are kept
+// for at most TTL and never beyond the expiry of the token itself, results
+// which did not authenticate are kept for NegativeTTL. Errors are not cached.
+type CachingValidator struct {
+	Validator   TokenValidator
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	hits   uint64
+	misses uint64
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cacheEntry
+	now     func() time.Time
+}
+
+// NewCachingValidator returns a CachingValidator wrapping the given TokenValidator
+func NewCachingValidator(v TokenValidator, ttl, negativeTTL time.Duration) *CachingValidator {
+	return &CachingValidator{
+		Validator:   v,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		entries:     make(map[[sha256.Size]byte]cacheEntry),
+		now:         time.Now,
+	}
+}
+
+// Validate returns the cached TokenReview for the token if there is one,
+// otherwise it calls the wrapped Validator and caches the result.
+func (c *CachingValidator) Validate(ctx context.Context, token string) (*authv1.TokenReview, error) {
+	key := sha256.Sum256([]byte(token))
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && now.After(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		return entry.review.DeepCopy(), nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	review, err := c.Validator.Validate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.NegativeTTL
+	if review.Status.Authenticated {
+		ttl = c.TTL
+	}
+	if ttl <= 0 {
+		return review, nil
+	}
+
+	expires := now.Add(ttl)
+	if exp, ok := tokenExpiry(token); ok && exp.Before(expires) {
+		expires = exp
+	}
+	if !expires.After(now) {
+		return review, nil
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxCacheEntries {
+		c.sweep(now)
+	}
+	c.entries[key] = cacheEntry{review: review.DeepCopy(), expires: expires}
+	c.mu.Unlock()
+
+	return review, nil
+}
+
+// Hits returns the number of validations answered from the cache
+func (c *CachingValidator) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+// Misses returns the number of validations passed to the wrapped Validator
+func (c *CachingValidator) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+// sweep removes expired entries, and if the cache is still full drops all of
+// them. It must be called with c.mu held.
+func (c *CachingValidator) sweep(now time.Time) {
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = make(map[[sha256.Size]byte]cacheEntry)
+	}
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it. The result is
+// only used to bound how long a review is cached, never to authenticate.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+func jwtWithExpiry(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "e30." + payload + ".c2ln"
+}
+
+func TestCachingValidator(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name          string
+		token         string
+		authenticated bool
+		err           error
+		advance       time.Duration
+		expectCalls   int
+	}{{
+		name:          "authenticated, within ttl",
+		token:         "THE_TOKEN",
+		authenticated: true,
+		advance:       30 * time.Second,
+		expectCalls:   1,
+	}, {
+		name:          "authenticated, ttl expired",
+		token:         "THE_TOKEN",
+		authenticated: true,
+		advance:       2 * time.Minute,
+		expectCalls:   2,
+	}, {
+		name:          "authenticated, token expired before ttl",
+		token:         jwtWithExpiry(start.Add(10 * time.Second)),
+		authenticated: true,
+		advance:       20 * time.Second,
+		expectCalls:   2,
+	}, {
+		name:        "not authenticated, within negative ttl",
+		token:       "THE_TOKEN",
+		advance:     time.Second,
+		expectCalls: 1,
+	}, {
+		name:        "not authenticated, negative ttl expired",
+		token:       "THE_TOKEN",
+		advance:     10 * time.Second,
+		expectCalls: 2,
+	}, {
+		name:        "errors are not cached",
+		token:       "THE_TOKEN",
+		err:         errors.New("some error"),
+		expectCalls: 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			now := start
+			cache := NewCachingValidator(TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+				calls++
+				if test.err != nil {
+					return nil, test.err
+				}
+				return &authv1.TokenReview{
+					Status: authv1.TokenReviewStatus{Authenticated: test.authenticated},
+				}, nil
+			}), time.Minute, 5*time.Second)
+			cache.now = func() time.Time { return now }
+
+			for i := 0; i < 2; i++ {
+				resp, err := cache.Validate(context.Background(), test.token)
+				if (err != nil) != (test.err != nil) {
+					t.Fatalf("expected error %v but got %v", test.err, err)
+				}
+				if err == nil && resp.Status.Authenticated != test.authenticated {
+					t.Errorf("expected authenticated to be %v but was %v", test.authenticated, resp.Status.Authenticated)
+				}
+				now = now.Add(test.advance)
+			}
+
+			if calls != test.expectCalls {
+				t.Errorf("expected %d calls to the validator but got %d", test.expectCalls, calls)
+			}
+			if got, want := cache.Misses(), uint64(test.expectCalls); got != want {
+				t.Errorf("expected %d misses but got %d", want, got)
+			}
+			if got, want := cache.Hits(), uint64(2-test.expectCalls); got != want {
+				t.Errorf("expected %d hits but got %d", want, got)
+			}
+		})
+	}
+}
+
+func TestCachingValidatorReturnsCopy(t *testing.T) {
+	cache := NewCachingValidator(TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+		return &authv1.TokenReview{
+			Status: authv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authv1.UserInfo{Username: "the-user"},
+			},
+		}, nil
+	}), time.Minute, time.Second)
+
+	first, _ := cache.Validate(context.Background(), "THE_TOKEN")
+	first.Status.User.Username = "changed"
+
+	second, _ := cache.Validate(context.Background(), "THE_TOKEN")
+	if got, want := second.Status.User.Username, "the-user"; got != want {
+		t.Errorf("expected cached username %q but got %q", want, got)
+	}
+}
@@ -0,0 +1,286 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"knative.dev/container-freezer/pkg/daemon/api"
+)
+
+// PodStatus is what the freezer did to the containers of a pod
+type PodStatus struct {
+	// Throttled is set when the containers were throttled rather than paused
+	Throttled  bool
+	Containers []string
+	FrozenAt   time.Time
+}
+
+// StatusReporter reports the pods the freezer has paused or throttled
+type StatusReporter interface {
+	// PodStatus returns the status of the pod and true if it is frozen
+	PodStatus(podUID string) (PodStatus, bool)
+}
+
+// StatusReporterFunc adapts a function to a StatusReporter
+type StatusReporterFunc func(podUID string) (PodStatus, bool)
+
+func (fn StatusReporterFunc) PodStatus(podUID string) (PodStatus, bool) {
+	return fn(podUID)
+}
+
+// GRPCServer serves the Freezer gRPC service, authenticating callers like the
+// Handler does with the token in their metadata
+type GRPCServer struct {
+	api.UnimplementedFreezerServer
+
+	Validator TokenValidator
+	// Authorizer is optional, if nil any pod with a valid token may freeze,
+	// thaw and watch itself. Freeze and Thaw are authorized as the "pause"
+	// and "resume" actions, Status as "get" and Watch as "watch".
+	Authorizer Authorizer
+	// Pods is optional, if set Freeze and Thaw may name another pod on the
+	// node, which the Authorizer must then allow
+	Pods    PodLookup
+	Freezer Freezer
+	Thawer  Thawer
+	// Reporter is optional, if nil the state of pods is reported as
+	// unspecified
+	Reporter StatusReporter
+	Logger   *zap.SugaredLogger
+	// AsyncFreeze answers Freeze calls straight away and freezes the pod in
+	// the background, like the Handler does
+	AsyncFreeze bool
+	// ErrorCode is the status code failed freezes and thaws are returned
+	// with, codes.Internal if nil
+	ErrorCode func(error) codes.Code
+
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+func (s *GRPCServer) Freeze(ctx context.Context, req *api.FreezeRequest) (*api.FreezeResponse, error) {
+	caller, err := s.caller(ctx, "pause", podRef(req.GetPod()))
+	if err != nil {
+		return nil, err
+	}
+
+	podUid := caller.TargetPodUID()
+	if s.AsyncFreeze {
+		s.Logger.Infof("freeze call received, queueing freeze of pod: %s", podUid)
+		freeze := queueFreeze(s.Freezer, podUid)
+		go func() {
+			// The call context ends with the response, so don't use it.
+			if err := freeze(context.Background()); err != nil {
+				s.Logger.Errorf("freezing pod %s failed: %v", podUid, err)
+			}
+		}()
+		return &api.FreezeResponse{Queued: true}, nil
+	}
+	s.Logger.Infof("freeze call received, freezing pod: %s", podUid)
+	if err := s.Freezer.Freeze(ctx, podUid); err != nil {
+		s.Logger.Errorf("freezing pod %s failed: %v", podUid, err)
+		return nil, s.statusError(err)
+	}
+	return &api.FreezeResponse{}, nil
+}
+
+func (s *GRPCServer) Thaw(ctx context.Context, req *api.ThawRequest) (*api.ThawResponse, error) {
+	caller, err := s.caller(ctx, "resume", podRef(req.GetPod()))
+	if err != nil {
+		return nil, err
+	}
+
+	podUid := caller.TargetPodUID()
+	s.Logger.Infof("thaw call received, thawing pod: %s", podUid)
+	if err := s.Thawer.Thaw(ctx, podUid); err != nil {
+		s.Logger.Errorf("thawing pod %s failed: %v", podUid, err)
+		return nil, s.statusError(err)
+	}
+	return &api.ThawResponse{}, nil
+}
+
+func (s *GRPCServer) Status(ctx context.Context, _ *api.StatusRequest) (*api.PodStatus, error) {
+	caller, err := s.caller(ctx, "get", nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.podStatus(caller.PodUID), nil
+}
+
+// Watch sends the status of the pod straight away, then again after every
+// change passed to Notify, until the caller goes away. Statuses may
+// be coalesced when the caller reads slower than the pod changes.
+func (s *GRPCServer) Watch(_ *api.WatchRequest, stream api.Freezer_WatchServer) error {
+	caller, err := s.caller(stream.Context(), "watch", nil)
+	if err != nil {
+		return err
+	}
+
+	changed := s.watch(caller.PodUID)
+	defer s.unwatch(caller.PodUID, changed)
+	for {
+		if err := stream.Send(s.podStatus(caller.PodUID)); err != nil {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Notify wakes up the watchers of the pod, to be called after it was frozen
+// or thawed, or its containers were resumed or exited behind the freezer's
+// back
+func (s *GRPCServer) Notify(podUID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.watchers[podUID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *GRPCServer) watch(podUID string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchers == nil {
+		s.watchers = make(map[string]map[chan struct{}]struct{})
+	}
+	if s.watchers[podUID] == nil {
+		s.watchers[podUID] = make(map[chan struct{}]struct{})
+	}
+	ch := make(chan struct{}, 1)
+	s.watchers[podUID][ch] = struct{}{}
+	return ch
+}
+
+func (s *GRPCServer) unwatch(podUID string, ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watchers[podUID], ch)
+	if len(s.watchers[podUID]) == 0 {
+		delete(s.watchers, podUID)
+	}
+}
+
+func (s *GRPCServer) podStatus(podUID string) *api.PodStatus {
+	ps := &api.PodStatus{PodUid: podUID, State: api.PodState_POD_STATE_RUNNING}
+	if s.Reporter == nil {
+		ps.State = api.PodState_POD_STATE_UNSPECIFIED
+		return ps
+	}
+	st, frozen := s.Reporter.PodStatus(podUID)
+	if !frozen {
+		return ps
+	}
+	ps.State = api.PodState_POD_STATE_FROZEN
+	if st.Throttled {
+		ps.State = api.PodState_POD_STATE_THROTTLED
+	}
+	ps.ContainerIds = st.Containers
+	if !st.FrozenAt.IsZero() {
+		ps.FrozenAt = timestamppb.New(st.FrozenAt)
+	}
+	return ps
+}
+
+// caller authenticates the pod making the call, and authorizes it to act on
+// itself or the target pod
+func (s *GRPCServer) caller(ctx context.Context, action string, target *PodRef) (*Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(api.TokenMetadataKey)
+	if len(tokens) == 0 || tokens[0] == "" {
+		s.Logger.Error("No token in metadata")
+		return nil, status.Error(codes.Unauthenticated, "no token in metadata")
+	}
+
+	resp, err := s.Validator.Validate(ctx, tokens[0])
+	if err != nil {
+		s.Logger.Error("Validating token failed")
+		return nil, status.Error(codes.Internal, "validating token failed")
+	}
+
+	if !resp.Status.Authenticated {
+		s.Logger.Error("Authenticating via token failed")
+		return nil, status.Error(codes.Unauthenticated, "token is not valid")
+	}
+
+	caller, err := CallerFromUserInfo(resp.Status.User)
+	if err == nil {
+		err = authorizeCall(ctx, s.Authorizer, s.Pods, caller, target, action)
+	}
+	if err != nil {
+		s.Logger.Errorf("Authorizing call failed: %v", err)
+		return nil, authorizationStatusError(err)
+	}
+	return caller, nil
+}
+
+func (s *GRPCServer) statusError(err error) error {
+	code := codes.Internal
+	if s.ErrorCode != nil {
+		code = s.ErrorCode(err)
+	}
+	return status.Error(code, err.Error())
+}
+
+// podRef converts the pod a request names, if any
+func podRef(pod *api.PodRef) *PodRef {
+	if pod == nil {
+		return nil
+	}
+	return &PodRef{Namespace: pod.Namespace, Name: pod.Name}
+}
+
+// ErrorDomain is the domain of the ErrorInfo detail rejected calls carry the
+// cause of the rejection in
+const ErrorDomain = "container-freezer.knative.dev"
+
+// authorizationStatusError is the status a rejected call fails with
+func authorizationStatusError(err error) error {
+	st := status.New(authorizationCode(err), err.Error())
+	var authzErr *AuthorizationError
+	if errors.As(err, &authzErr) && authzErr.Cause != "" {
+		if withCause, detailErr := st.WithDetails(&errdetails.ErrorInfo{Reason: authzErr.Cause, Domain: ErrorDomain}); detailErr == nil {
+			st = withCause
+		}
+	}
+	return st.Err()
+}
+
+// authorizationCode is the gRPC counterpart of the HTTP status code of an
+// *AuthorizationError
+func authorizationCode(err error) codes.Code {
+	var authzErr *AuthorizationError
+	if !errors.As(err, &authzErr) {
+		return codes.Internal
+	}
+	switch authzErr.Code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	// The caller is authenticated but not allowed, whichever status code
+	// the Handler distinguishes the reason with
+	case http.StatusForbidden, http.StatusMisdirectedRequest:
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,343 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+
+	"knative.dev/container-freezer/pkg/daemon"
+	"knative.dev/container-freezer/pkg/daemon/api"
+	ltesting "knative.dev/pkg/logging/testing"
+)
+
+var podTokens = daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+	switch token {
+	case "THE_TOKEN":
+		return &authv1.TokenReview{
+			Status: authv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authv1.UserInfo{
+					Extra: map[string]authv1.ExtraValue{
+						"authentication.kubernetes.io/pod-uid": {"the-pod-uid"},
+					},
+				},
+			},
+		}, nil
+	case "INVALID_TOKEN":
+		return &authv1.TokenReview{}, nil
+	default:
+		return nil, errors.New("token review failed")
+	}
+})
+
+// fakePods is a Freezer, Thawer and StatusReporter which freezes pods
+// without containers
+type fakePods struct {
+	mu     sync.Mutex
+	frozen map[string]bool
+	err    error
+}
+
+func (f *fakePods) Freeze(_ context.Context, podName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.frozen[podName] = true
+	return nil
+}
+
+func (f *fakePods) Thaw(_ context.Context, podName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	delete(f.frozen, podName)
+	return nil
+}
+
+func (f *fakePods) PodStatus(podUID string) (daemon.PodStatus, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return daemon.PodStatus{Containers: []string{"ctr"}}, f.frozen[podUID]
+}
+
+// serveGRPC serves the server on a loopback port and returns a client for it
+func serveGRPC(t *testing.T, server *daemon.GRPCServer, token string) api.FreezerClient {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	api.RegisterFreezerServer(s, server)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(api.Token(token)))
+	}
+	conn, err := grpc.Dial(l.Addr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewFreezerClient(conn)
+}
+
+// errorCause returns the cause a rejected call carries, if any
+func errorCause(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == daemon.ErrorDomain {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGRPCServer(t *testing.T) {
+	tt := []struct {
+		name        string
+		token       string
+		authorizer  daemon.Authorizer
+		err         error
+		expectCode  codes.Code
+		expectCause string
+	}{{
+		name:       "no token",
+		expectCode: codes.Unauthenticated,
+	}, {
+		name:       "token not valid",
+		token:      "INVALID_TOKEN",
+		expectCode: codes.Unauthenticated,
+	}, {
+		name:       "token validation fails",
+		token:      "OTHER_TOKEN",
+		expectCode: codes.Internal,
+	}, {
+		name:  "not authorized",
+		token: "THE_TOKEN",
+		authorizer: daemon.AuthorizerFunc(func(context.Context, *daemon.Caller, string) error {
+			return &daemon.AuthorizationError{Code: http.StatusMisdirectedRequest, Cause: daemon.CauseOtherNode, Reason: "wrong node"}
+		}),
+		expectCode:  codes.PermissionDenied,
+		expectCause: daemon.CauseOtherNode,
+	}, {
+		name:       "freeze fails",
+		token:      "THE_TOKEN",
+		err:        errors.New("runtime call timed out"),
+		expectCode: codes.DeadlineExceeded,
+	}, {
+		name:       "valid token",
+		token:      "THE_TOKEN",
+		expectCode: codes.OK,
+	}}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			pods := &fakePods{frozen: make(map[string]bool), err: test.err}
+			client := serveGRPC(t, &daemon.GRPCServer{
+				Logger:     ltesting.TestLogger(t),
+				Validator:  podTokens,
+				Authorizer: test.authorizer,
+				Freezer:    pods,
+				Thawer:     pods,
+				Reporter:   pods,
+				ErrorCode: func(error) codes.Code {
+					return codes.DeadlineExceeded
+				},
+			}, test.token)
+			ctx := context.Background()
+
+			_, err := client.Freeze(ctx, &api.FreezeRequest{})
+			if got := status.Code(err); got != test.expectCode {
+				t.Fatalf("Expected freeze to return %v but got %v", test.expectCode, err)
+			}
+			if got := errorCause(err); got != test.expectCause {
+				t.Errorf("Expected cause %q but got %q", test.expectCause, got)
+			}
+			if test.expectCode != codes.OK {
+				return
+			}
+
+			st, err := client.Status(ctx, &api.StatusRequest{})
+			if err != nil {
+				t.Fatalf("Expected status to succeed but got %v", err)
+			}
+			if st.PodUid != "the-pod-uid" || st.State != api.PodState_POD_STATE_FROZEN {
+				t.Errorf("Expected the pod to be frozen but got %v", st)
+			}
+
+			if _, err := client.Thaw(ctx, &api.ThawRequest{}); err != nil {
+				t.Fatalf("Expected thaw to succeed but got %v", err)
+			}
+			if st, err := client.Status(ctx, &api.StatusRequest{}); err != nil || st.State != api.PodState_POD_STATE_RUNNING {
+				t.Errorf("Expected the pod to be running but got %v, %v", st, err)
+			}
+		})
+	}
+}
+
+func TestGRPCServerAuthorizesActions(t *testing.T) {
+	var actions []string
+	pods := &fakePods{frozen: make(map[string]bool)}
+	client := serveGRPC(t, &daemon.GRPCServer{
+		Logger:    ltesting.TestLogger(t),
+		Validator: podTokens,
+		Authorizer: daemon.AuthorizerFunc(func(_ context.Context, _ *daemon.Caller, action string) error {
+			actions = append(actions, action)
+			return nil
+		}),
+		Freezer:  pods,
+		Thawer:   pods,
+		Reporter: pods,
+	}, "THE_TOKEN")
+	ctx := context.Background()
+
+	client.Freeze(ctx, &api.FreezeRequest{})
+	client.Thaw(ctx, &api.ThawRequest{})
+	client.Status(ctx, &api.StatusRequest{})
+	stream, err := client.Watch(ctx, &api.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"pause", "resume", "get", "watch"}
+	if len(actions) != len(want) {
+		t.Fatalf("Expected actions %v but got %v", want, actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("Expected actions %v but got %v", want, actions)
+		}
+	}
+}
+
+func TestGRPCServerWatch(t *testing.T) {
+	pods := &fakePods{frozen: make(map[string]bool)}
+	server := &daemon.GRPCServer{
+		Logger:    ltesting.TestLogger(t),
+		Validator: podTokens,
+		Freezer:   pods,
+		Thawer:    pods,
+		Reporter:  pods,
+	}
+	client := serveGRPC(t, server, "THE_TOKEN")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &api.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := stream.Recv()
+	if err != nil || st.State != api.PodState_POD_STATE_RUNNING {
+		t.Fatalf("Expected the pod to be running but got %v, %v", st, err)
+	}
+
+	// The transitions of the runtime are passed to Notify
+	pods.Freeze(ctx, "the-pod-uid")
+	server.Notify("the-pod-uid")
+	st, err = stream.Recv()
+	if err != nil || st.State != api.PodState_POD_STATE_FROZEN {
+		t.Fatalf("Expected the pod to be frozen but got %v, %v", st, err)
+	}
+	if len(st.ContainerIds) != 1 || st.ContainerIds[0] != "ctr" {
+		t.Errorf("Expected the paused containers to be reported but got %v", st.ContainerIds)
+	}
+}
+
+func TestGRPCServerAsyncFreezeQueued(t *testing.T) {
+	freezer := &queueingFreezer{queued: make(chan string, 1), frozen: make(chan string, 1)}
+	client := serveGRPC(t, &daemon.GRPCServer{
+		Logger:      ltesting.TestLogger(t),
+		Validator:   podTokens,
+		Freezer:     freezer,
+		AsyncFreeze: true,
+	}, "THE_TOKEN")
+
+	resp, err := client.Freeze(context.Background(), &api.FreezeRequest{})
+	if err != nil || !resp.Queued {
+		t.Fatalf("Expected the freeze to be queued but got %v, %v", resp, err)
+	}
+	// A thaw sent after the response must find the freeze registered
+	select {
+	case <-freezer.queued:
+	default:
+		t.Fatal("Expected the freeze to be queued before responding")
+	}
+	select {
+	case <-freezer.frozen:
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the pod to be frozen")
+	}
+}
+
+func TestGRPCServerTargetPod(t *testing.T) {
+	pods := &fakePods{frozen: make(map[string]bool)}
+	client := serveGRPC(t, &daemon.GRPCServer{
+		Logger:    ltesting.TestLogger(t),
+		Validator: podTokens,
+		Authorizer: daemon.AuthorizerFunc(func(_ context.Context, caller *daemon.Caller, _ string) error {
+			if caller.Target == nil || caller.Target.Namespace != "apps" {
+				return &daemon.AuthorizationError{Code: http.StatusForbidden, Reason: "not allowed"}
+			}
+			return nil
+		}),
+		Pods: podLookupFunc(func(_ context.Context, namespace, name string) (string, error) {
+			if name != "api-1" {
+				return "", &daemon.AuthorizationError{Code: http.StatusNotFound, Reason: "not found"}
+			}
+			return "api-1-uid", nil
+		}),
+		Freezer:  pods,
+		Thawer:   pods,
+		Reporter: pods,
+	}, "THE_TOKEN")
+	ctx := context.Background()
+
+	if _, err := client.Freeze(ctx, &api.FreezeRequest{Pod: &api.PodRef{Namespace: "apps", Name: "api-1"}}); err != nil {
+		t.Fatalf("Expected freeze to succeed but got %v", err)
+	}
+	if _, frozen := pods.PodStatus("api-1-uid"); !frozen {
+		t.Error("Expected the target pod to be frozen")
+	}
+	if _, err := client.Thaw(ctx, &api.ThawRequest{Pod: &api.PodRef{Namespace: "apps", Name: "api-1"}}); err != nil {
+		t.Fatalf("Expected thaw to succeed but got %v", err)
+	}
+	if _, frozen := pods.PodStatus("api-1-uid"); frozen {
+		t.Error("Expected the target pod to be thawed")
+	}
+
+	_, err := client.Freeze(ctx, &api.FreezeRequest{Pod: &api.PodRef{Namespace: "apps", Name: "api-2"}})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("Expected freezing an unknown pod to return %v but got %v", codes.NotFound, err)
+	}
+	_, err = client.Freeze(ctx, &api.FreezeRequest{Pod: &api.PodRef{Namespace: "other", Name: "api-1"}})
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Errorf("Expected freezing a pod of another namespace to return %v but got %v", codes.PermissionDenied, err)
+	}
+}
+
+type podLookupFunc func(ctx context.Context, namespace, name string) (string, error)
+
+func (fn podLookupFunc) PodUID(ctx context.Context, namespace, name string) (string, error) {
+	return fn(ctx, namespace, name)
+}
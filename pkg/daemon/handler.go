@@ -3,6 +3,7 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"go.uber.org/zap"
@@ -11,6 +12,10 @@ import (
 
 const TokenHeaderKey = "Token"
 
+// CauseHeaderKey is the header rejected requests are answered with the cause
+// of the rejection in
+const CauseHeaderKey = "Cause"
+
 type TokenValidator interface {
 	Validate(ctx context.Context, token string) (*authv1.TokenReview, error)
 }
@@ -23,6 +28,24 @@ type Thawer interface {
 	Thaw(ctx context.Context, podName string) error
 }
 
+// FreezeQueuer is implemented by freezers which can register a freeze before
+// performing it, so that a resume arriving after a queued pause was answered
+// always goes ahead of it
+type FreezeQueuer interface {
+	QueueFreeze(podName string) func(ctx context.Context) error
+}
+
+// queueFreeze registers a freeze of the pod if the freezer supports it, and
+// returns the function which performs it
+func queueFreeze(f Freezer, podName string) func(ctx context.Context) error {
+	if q, ok := f.(FreezeQueuer); ok {
+		return q.QueueFreeze(podName)
+	}
+	return func(ctx context.Context) error {
+		return f.Freeze(ctx, podName)
+	}
+}
+
 type FreezeThawer interface {
 	Freezer
 	Thawer
@@ -30,9 +53,17 @@ type FreezeThawer interface {
 
 type Handler struct {
 	Validator TokenValidator
-	Freezer   Freezer
-	Thawer    Thawer
-	Logger    *zap.SugaredLogger
+	// Authorizer is optional, if nil any pod with a valid token may pause and resume itself
+	Authorizer Authorizer
+	// Pods is optional, if set requests may name another pod on the node to
+	// pause or resume, which the Authorizer must then allow
+	Pods    PodLookup
+	Freezer Freezer
+	Thawer  Thawer
+	Logger  *zap.SugaredLogger
+	// AsyncFreeze answers pause requests with 202 Accepted and freezes the
+	// pod in the background, resume requests are always synchronous
+	AsyncFreeze bool
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -60,11 +91,38 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 		h.Logger.Errorf("Unable to decode message body: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	caller, err := CallerFromUserInfo(resp.Status.User)
+	if err == nil {
+		err = authorizeCall(r.Context(), h.Authorizer, h.Pods, caller, m.Pod, m.Action)
+	}
+	if err != nil {
+		h.Logger.Errorf("Authorizing request failed: %v", err)
+		var authzErr *AuthorizationError
+		if errors.As(err, &authzErr) && authzErr.Cause != "" {
+			w.Header().Set(CauseHeaderKey, authzErr.Cause)
+		}
+		w.WriteHeader(authorizationStatus(err))
+		return
 	}
 
-	podUid := resp.Status.User.Extra["authentication.kubernetes.io/pod-uid"][0]
+	podUid := caller.TargetPodUID()
 	switch m.Action {
 	case "pause":
+		if h.AsyncFreeze {
+			h.Logger.Infof("pause request received, queueing freeze of pod: %s", podUid)
+			freeze := queueFreeze(h.Freezer, podUid)
+			go func() {
+				// The request context ends with the response, so don't use it.
+				if err := freeze(context.Background()); err != nil {
+					h.Logger.Errorf("freezing pod %s failed: %v", podUid, err)
+				}
+			}()
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
 		h.Logger.Infof("pause request received, freezing pod: %s", podUid)
 		if err = h.Freezer.Freeze(r.Context(), podUid); err != nil {
 			h.Logger.Errorf("freezing pod %s failed: %v", podUid, err)
@@ -80,8 +138,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func authorizationStatus(err error) int {
+	var authzErr *AuthorizationError
+	if errors.As(err, &authzErr) {
+		return authzErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
 type messageBody struct {
 	Action string `json:"action"`
+	// Pod is the pod to act on, if not the caller's
+	Pod *PodRef `json:"pod,omitempty"`
 }
 
 type TokenValidatorFunc func(ctx context.Context, token string) (*authv1.TokenReview, error)
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	authv1 "k8s.io/api/authentication/v1"
 	"knative.dev/container-freezer/pkg/daemon"
@@ -24,9 +25,11 @@ func TestHandler(t *testing.T) {
 		headers      http.Header
 		tokens       map[string]*authv1.TokenReview
 		expectStatus int
+		expectCause  string
 		expectFreeze string
 		expectThaw   string
 		body         *bytes.Buffer
+		authorizer   daemon.Authorizer
 	}{{
 		name:    "no token",
 		body:    pauseBody,
@@ -98,6 +101,45 @@ func TestHandler(t *testing.T) {
 
 		expectStatus: 200,
 		expectThaw:   "the-pod-uid",
+	}, {
+		name: "valid token, no pod-uid claim",
+		body: bytes.NewBufferString(pauseBodyText),
+		tokens: map[string]*authv1.TokenReview{
+			"THE_TOKEN": {
+				Status: authv1.TokenReviewStatus{
+					Authenticated: true,
+				},
+			},
+		},
+		headers: http.Header{
+			daemon.TokenHeaderKey: []string{"THE_TOKEN"},
+		},
+
+		expectStatus: 400,
+		expectCause:  daemon.CausePodUIDMissing,
+	}, {
+		name: "valid token, not authorized",
+		body: bytes.NewBufferString(pauseBodyText),
+		tokens: map[string]*authv1.TokenReview{
+			"THE_TOKEN": {
+				Status: authv1.TokenReviewStatus{
+					Authenticated: true,
+					User: authv1.UserInfo{
+						Username: "system:serviceaccount:other:default",
+						Extra: map[string]authv1.ExtraValue{
+							"authentication.kubernetes.io/pod-uid": {"the-pod-uid"},
+						},
+					},
+				},
+			},
+		},
+		headers: http.Header{
+			daemon.TokenHeaderKey: []string{"THE_TOKEN"},
+		},
+		authorizer: &daemon.ClaimsAuthorizer{Namespaces: []string{"default"}},
+
+		expectStatus: 403,
+		expectCause:  daemon.CauseNamespaceNotAllowed,
 	}}
 
 	for _, test := range tt {
@@ -105,7 +147,8 @@ func TestHandler(t *testing.T) {
 			logger := ltesting.TestLogger(t)
 			var thawed, froze string
 			handler := daemon.Handler{
-				Logger: logger,
+				Logger:     logger,
+				Authorizer: test.authorizer,
 				Validator: daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
 					resp, ok := test.tokens[token]
 					if !ok {
@@ -132,6 +175,9 @@ func TestHandler(t *testing.T) {
 			if got, want := resp.Code, test.expectStatus; got != want {
 				t.Errorf("Expected response code %v but was %v", want, got)
 			}
+			if got, want := resp.Header().Get(daemon.CauseHeaderKey), test.expectCause; got != want {
+				t.Errorf("Expected cause %q but was %q", want, got)
+			}
 
 			if got, want := froze, test.expectFreeze; got != want {
 				t.Errorf("Expected frozen to be %q but was %q", want, got)
@@ -144,6 +190,63 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestHandlerAsyncFreeze(t *testing.T) {
+	logger := ltesting.TestLogger(t)
+	frozen := make(chan string, 1)
+	handler := daemon.Handler{
+		Logger:      logger,
+		AsyncFreeze: true,
+		Validator: daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+			return &authv1.TokenReview{
+				Status: authv1.TokenReviewStatus{
+					Authenticated: true,
+					User: authv1.UserInfo{
+						Extra: map[string]authv1.ExtraValue{
+							"authentication.kubernetes.io/pod-uid": {"the-pod-uid"},
+						},
+					},
+				},
+			}, nil
+		}),
+		Freezer: FreezeFunc(func(_ context.Context, podName string) error {
+			frozen <- podName
+			return nil
+		}),
+		Thawer: ThawFunc(func(_ context.Context, podName string) error {
+			return nil
+		}),
+	}
+
+	for _, test := range []struct {
+		action       string
+		expectStatus int
+	}{{
+		action:       "pause",
+		expectStatus: 202,
+	}, {
+		action:       "resume",
+		expectStatus: 200,
+	}} {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(fmt.Sprintf(`{ "action": %q }`, test.action)))
+		req.Header.Set(daemon.TokenHeaderKey, "THE_TOKEN")
+		handler.ServeHTTP(resp, req)
+
+		if got, want := resp.Code, test.expectStatus; got != want {
+			t.Errorf("Expected response code %v for %s but was %v", want, test.action, got)
+		}
+	}
+
+	select {
+	case podName := <-frozen:
+		if podName != "the-pod-uid" {
+			t.Errorf("Expected frozen to be %q but was %q", "the-pod-uid", podName)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the pod to be frozen")
+	}
+}
+
 type FreezeFunc func(ctx context.Context, podName string) error
 
 func (fn FreezeFunc) Freeze(ctx context.Context, podName string) error {
@@ -155,3 +258,62 @@ type ThawFunc func(ctx context.Context, podName string) error
 func (fn ThawFunc) Thaw(ctx context.Context, podName string) error {
 	return fn(ctx, podName)
 }
+
+// queueingFreezer records when freezes are queued and performed
+type queueingFreezer struct {
+	queued chan string
+	frozen chan string
+}
+
+func (q *queueingFreezer) Freeze(_ context.Context, podName string) error {
+	q.frozen <- podName
+	return nil
+}
+
+func (q *queueingFreezer) QueueFreeze(podName string) func(ctx context.Context) error {
+	q.queued <- podName
+	return func(ctx context.Context) error {
+		return q.Freeze(ctx, podName)
+	}
+}
+
+func TestHandlerAsyncFreezeQueued(t *testing.T) {
+	freezer := &queueingFreezer{queued: make(chan string, 1), frozen: make(chan string, 1)}
+	handler := daemon.Handler{
+		Logger:      ltesting.TestLogger(t),
+		AsyncFreeze: true,
+		Validator: daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+			return &authv1.TokenReview{
+				Status: authv1.TokenReviewStatus{
+					Authenticated: true,
+					User: authv1.UserInfo{
+						Extra: map[string]authv1.ExtraValue{
+							"authentication.kubernetes.io/pod-uid": {"the-pod-uid"},
+						},
+					},
+				},
+			}, nil
+		}),
+		Freezer: freezer,
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", bytes.NewBufferString(`{ "action": "pause" }`))
+	req.Header.Set(daemon.TokenHeaderKey, "THE_TOKEN")
+	handler.ServeHTTP(resp, req)
+
+	if got, want := resp.Code, 202; got != want {
+		t.Errorf("Expected response code %v but was %v", want, got)
+	}
+	// A resume sent after the response must find the freeze registered
+	select {
+	case <-freezer.queued:
+	default:
+		t.Fatal("Expected the freeze to be queued before responding")
+	}
+	select {
+	case <-freezer.frozen:
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the pod to be frozen")
+	}
+}
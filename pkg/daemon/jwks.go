@@ -0,0 +1,506 @@
+package daemon
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	jwksPath      = "/openid/v1/jwks"
+
+	// jwtLeeway is the clock skew tolerated when checking exp, nbf and iat
+	jwtLeeway = 30 * time.Second
+
+	// minKeyRefreshInterval limits how often the keys are fetched, whether
+	// because of an unknown key ID or because the API server was unavailable
+	minKeyRefreshInterval = 10 * time.Second
+
+	// keyRefreshTimeout bounds fetching the keys, which callers share
+	keyRefreshTimeout = 10 * time.Second
+)
+
+// JWKSValidator is a TokenValidator which verifies projected service account
+// tokens locally against the API server's signing keys, rather than calling
+// the TokenReview API for every request.
+type JWKSValidator struct {
+	// Client is used to fetch the discovery document and keys from the API server
+	Client *http.Client
+	// Host is the base URL of the API server
+	Host string
+	// Audiences are the audiences of which the token must have at least one
+	Audiences []string
+	// Issuer is the expected issuer, if empty it is read from the discovery document
+	Issuer string
+	// RefreshInterval is how often the keys are re-fetched
+	RefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	refreshErr  error
+	refreshes   singleflight.Group
+	now         func() time.Time
+}
+
+// NewJWKSValidator returns a JWKSValidator for the API server at host
+func NewJWKSValidator(client *http.Client, host string, audiences []string, refreshInterval time.Duration) *JWKSValidator {
+	return &JWKSValidator{
+		Client:          client,
+		Host:            strings.TrimSuffix(host, "/"),
+		Audiences:       audiences,
+		RefreshInterval: refreshInterval,
+		now:             time.Now,
+	}
+}
+
+// errInvalidToken is wrapped by all errors which mean the token itself is bad,
+// as opposed to the keys being unavailable.
+var errInvalidToken = errors.New("invalid token")
+
+// Validate verifies the token and returns the equivalent of a TokenReview
+// response. A token which fails verification results in an unauthenticated
+// review, an error is only returned if the keys could not be fetched.
+func (v *JWKSValidator) Validate(ctx context.Context, token string) (*authv1.TokenReview, error) {
+	claims, err := v.verify(ctx, token)
+	if errors.Is(err, errInvalidToken) {
+		return &authv1.TokenReview{
+			Status: authv1.TokenReviewStatus{
+				Authenticated: false,
+				Error:         err.Error(),
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claims.tokenReview(v.Audiences), nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type podClaims struct {
+	Namespace string `json:"namespace"`
+	Pod       *struct {
+		Name string `json:"name"`
+		UID  string `json:"uid"`
+	} `json:"pod"`
+	ServiceAccount *struct {
+		Name string `json:"name"`
+		UID  string `json:"uid"`
+	} `json:"serviceaccount"`
+	Node *struct {
+		Name string `json:"name"`
+		UID  string `json:"uid"`
+	} `json:"node"`
+}
+
+type jwtClaims struct {
+	Issuer     string     `json:"iss"`
+	Subject    string     `json:"sub"`
+	Audience   audience   `json:"aud"`
+	Expiry     int64      `json:"exp"`
+	NotBefore  int64      `json:"nbf"`
+	IssuedAt   int64      `json:"iat"`
+	Kubernetes *podClaims `json:"kubernetes.io"`
+}
+
+// audience is the aud claim, which may be either a string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+func (v *JWKSValidator) verify(ctx context.Context, token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed jwt", errInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header: %v", errInvalidToken, err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature: %v", errInvalidToken, err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims: %v", errInvalidToken, err)
+	}
+	if err := v.checkClaims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+	return &claims, nil
+}
+
+func (v *JWKSValidator) checkClaims(claims *jwtClaims) error {
+	now := v.now()
+
+	v.mu.RLock()
+	issuer := v.Issuer
+	v.mu.RUnlock()
+	if issuer == "" {
+		return errors.New("issuer is unknown")
+	}
+	if claims.Issuer != issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+
+	if !audiencesIntersect(claims.Audience, v.Audiences) {
+		return fmt.Errorf("token audiences %v do not match %v", claims.Audience, v.Audiences)
+	}
+
+	if claims.Expiry == 0 {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(claims.Expiry, 0).Add(jwtLeeway)) {
+		return errors.New("token has expired")
+	}
+	if claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if claims.IssuedAt != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return errors.New("token was issued in the future")
+	}
+
+	k := claims.Kubernetes
+	if k == nil || k.Namespace == "" || k.Pod == nil || k.Pod.UID == "" || k.ServiceAccount == nil || k.ServiceAccount.Name == "" {
+		return errors.New("token is not bound to a pod")
+	}
+	if want := "system:serviceaccount:" + k.Namespace + ":" + k.ServiceAccount.Name; claims.Subject != want {
+		return fmt.Errorf("subject %q does not match service account %q", claims.Subject, want)
+	}
+	return nil
+}
+
+// tokenReview builds the TokenReview the API server would have returned for
+// the claims, so that the Handler can treat both validators the same.
+func (c *jwtClaims) tokenReview(audiences []string) *authv1.TokenReview {
+	k := c.Kubernetes
+	extra := map[string]authv1.ExtraValue{
+		"authentication.kubernetes.io/pod-name": {k.Pod.Name},
+		"authentication.kubernetes.io/pod-uid":  {k.Pod.UID},
+	}
+	if k.Node != nil {
+		extra["authentication.kubernetes.io/node-name"] = authv1.ExtraValue{k.Node.Name}
+		extra["authentication.kubernetes.io/node-uid"] = authv1.ExtraValue{k.Node.UID}
+	}
+
+	var matched []string
+	for _, a := range c.Audience {
+		for _, want := range audiences {
+			if a == want {
+				matched = append(matched, a)
+			}
+		}
+	}
+
+	return &authv1.TokenReview{
+		Status: authv1.TokenReviewStatus{
+			Authenticated: true,
+			Audiences:     matched,
+			User: authv1.UserInfo{
+				Username: c.Subject,
+				UID:      k.ServiceAccount.UID,
+				Groups: []string{
+					"system:serviceaccounts",
+					"system:serviceaccounts:" + k.Namespace,
+					"system:authenticated",
+				},
+				Extra: extra,
+			},
+		},
+	}
+}
+
+func audiencesIntersect(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, into interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, into)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var h hash.Hash
+	var ch crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		h, ch = sha256.New(), crypto.SHA256
+	case "RS384", "ES384":
+		h, ch = sha512.New384(), crypto.SHA384
+	case "RS512", "ES512":
+		h, ch = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %q does not match RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(k, ch, digest, sig)
+	case *ecdsa.PublicKey:
+		if curve, ok := ecCurves[alg]; !ok || k.Curve != curve {
+			return fmt.Errorf("algorithm %q does not match EC key on curve %s", alg, k.Curve.Params().Name)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("malformed ECDSA signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// ecCurves are the curves of the ECDSA signing algorithms
+var ecCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// key returns the public key with the given ID, refreshing the key set if it
+// is stale or does not contain the key. The keys are fetched at most once per
+// minKeyRefreshInterval, so that tokens with made up key IDs or an
+// unavailable API server do not cause a fetch per request.
+func (v *JWKSValidator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	fetched := v.keys != nil
+	fetchedAt, attemptedAt, refreshErr := v.fetchedAt, v.attemptedAt, v.refreshErr
+	v.mu.RUnlock()
+
+	now := v.now()
+	stale := !fetched || (v.RefreshInterval > 0 && now.Sub(fetchedAt) > v.RefreshInterval)
+	if ok && !stale {
+		return key, nil
+	}
+	if !attemptedAt.IsZero() && now.Sub(attemptedAt) < minKeyRefreshInterval {
+		switch {
+		case ok:
+			return key, nil
+		case !fetched:
+			return nil, refreshErr
+		}
+		return nil, fmt.Errorf("%w: unknown key id %q", errInvalidToken, kid)
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		if ok {
+			// Keep using the last known key if the API server is unavailable.
+			return key, nil
+		}
+		return nil, err
+	}
+
+	v.mu.RLock()
+	key, ok = v.keys[kid]
+	v.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id %q", errInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// refresh fetches the discovery document and key set from the API server.
+// Concurrent callers share a single fetch, which is not given up on when one
+// of them is.
+func (v *JWKSValidator) refresh(ctx context.Context) error {
+	ch := v.refreshes.DoChan("refresh", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), keyRefreshTimeout)
+		defer cancel()
+		err := v.fetch(ctx)
+		v.mu.Lock()
+		v.attemptedAt = v.now()
+		v.refreshErr = err
+		v.mu.Unlock()
+		return nil, err
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-ch:
+		return res.Err
+	}
+}
+
+func (v *JWKSValidator) fetch(ctx context.Context) error {
+	var discovery struct {
+		Issuer string `json:"issuer"`
+	}
+	if err := v.get(ctx, discoveryPath, &discovery); err != nil {
+		return fmt.Errorf("fetching OIDC discovery document: %w", err)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.get(ctx, jwksPath, &set); err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			// Skip keys we can't use rather than failing on all of them.
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.Issuer == "" {
+		if discovery.Issuer == "" {
+			return errors.New("OIDC discovery document has no issuer")
+		}
+		v.Issuer = discovery.Issuer
+	}
+	v.keys = keys
+	v.fetchedAt = v.now()
+	return nil
+}
+
+func (v *JWKSValidator) get(ctx context.Context, path string, into interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Host+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, fmt.Errorf("key %q is not a signing key", k.Kid)
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,346 @@
+package daemon
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://kubernetes.default.svc.cluster.local"
+
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func (s testSigner) jwk() map[string]string {
+	switch k := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": s.kid, "use": "sig", "alg": s.alg,
+			"n": base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return map[string]string{
+			"kty": "EC", "kid": s.kid, "use": "sig", "alg": s.alg, "crv": k.Curve.Params().Name,
+			"x": base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			"y": base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}
+	}
+	return nil
+}
+
+func (s testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, ss, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), ss.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func podTokenClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": testIssuer,
+		"sub": "system:serviceaccount:default:the-sa",
+		"aud": []string{"concurrency-state-hook"},
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"kubernetes.io": map[string]interface{}{
+			"namespace":      "default",
+			"pod":            map[string]string{"name": "the-pod", "uid": "the-pod-uid"},
+			"serviceaccount": map[string]string{"name": "the-sa", "uid": "the-sa-uid"},
+			"node":           map[string]string{"name": "the-node", "uid": "the-node-uid"},
+		},
+	}
+}
+
+func runKeyServer(t *testing.T, issuer string, signers ...testSigner) (*httptest.Server, *int32) {
+	var fetches int32
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer})
+	})
+	mux.HandleFunc(jwksPath, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		var keys []map[string]string
+		for _, s := range signers {
+			keys = append(keys, s.jwk())
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestJWKSValidator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaSigner := testSigner{kid: "rsa", alg: "RS256", key: rsaKey}
+	ecSigner := testSigner{kid: "ec", alg: "ES256", key: ecKey}
+	unknownSigner := testSigner{kid: "rsa", alg: "RS256", key: otherKey}
+	curveSigner := testSigner{kid: "p384", alg: "ES256", key: p384Key}
+
+	now := time.Unix(1700000000, 0)
+	server, _ := runKeyServer(t, testIssuer, rsaSigner, ecSigner, curveSigner)
+
+	tests := []struct {
+		name         string
+		signer       testSigner
+		mutate       func(map[string]interface{})
+		expectAuthed bool
+	}{{
+		name:         "valid RSA token",
+		signer:       rsaSigner,
+		expectAuthed: true,
+	}, {
+		name:         "valid EC token",
+		signer:       ecSigner,
+		expectAuthed: true,
+	}, {
+		name:   "signed by unknown key",
+		signer: unknownSigner,
+	}, {
+		name:   "algorithm does not match curve",
+		signer: curveSigner,
+	}, {
+		name:   "wrong audience",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { c["aud"] = "something-else" },
+	}, {
+		name:   "wrong issuer",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" },
+	}, {
+		name:   "expired",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() },
+	}, {
+		name:   "not valid yet",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { c["nbf"] = now.Add(time.Hour).Unix() },
+	}, {
+		name:   "not bound to a pod",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { delete(c, "kubernetes.io") },
+	}, {
+		name:   "subject does not match service account",
+		signer: rsaSigner,
+		mutate: func(c map[string]interface{}) { c["sub"] = "system:serviceaccount:default:other" },
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Hour)
+			v.now = func() time.Time { return now }
+
+			claims := podTokenClaims(now)
+			if test.mutate != nil {
+				test.mutate(claims)
+			}
+
+			resp, err := v.Validate(context.Background(), test.signer.sign(t, claims))
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+			if got, want := resp.Status.Authenticated, test.expectAuthed; got != want {
+				t.Fatalf("expected authenticated to be %v but was %v (%s)", want, got, resp.Status.Error)
+			}
+			if !test.expectAuthed {
+				return
+			}
+
+			if got, want := resp.Status.User.Username, "system:serviceaccount:default:the-sa"; got != want {
+				t.Errorf("expected username %q but got %q", want, got)
+			}
+			if got, want := resp.Status.User.Extra["authentication.kubernetes.io/pod-uid"], []string{"the-pod-uid"}; len(got) != 1 || got[0] != want[0] {
+				t.Errorf("expected pod-uid %v but got %v", want, got)
+			}
+			if got, want := resp.Status.User.Extra["authentication.kubernetes.io/node-name"], []string{"the-node"}; len(got) != 1 || got[0] != want[0] {
+				t.Errorf("expected node-name %v but got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestJWKSValidatorRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSigner{kid: "rsa", alg: "RS256", key: key}
+	server, fetches := runKeyServer(t, testIssuer, signer)
+
+	now := time.Unix(1700000000, 0)
+	v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Minute)
+	v.now = func() time.Time { return now }
+
+	token := signer.sign(t, podTokenClaims(now))
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate(context.Background(), token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if atomic.LoadInt32(fetches) != 1 {
+		t.Errorf("expected keys to be fetched once but were fetched %d times", atomic.LoadInt32(fetches))
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Validate(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(fetches) != 2 {
+		t.Errorf("expected keys to be refreshed after the interval but were fetched %d times", atomic.LoadInt32(fetches))
+	}
+}
+
+func TestJWKSValidatorKeysUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Minute)
+	if _, err := v.Validate(context.Background(), "e30.e30.c2ln"); err == nil {
+		t.Error("expected an error when the keys can't be fetched")
+	}
+}
+
+func TestJWKSValidatorNoIssuer(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSigner{kid: "rsa", alg: "RS256", key: key}
+	server, _ := runKeyServer(t, "", signer)
+
+	now := time.Unix(1700000000, 0)
+	v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Minute)
+	v.now = func() time.Time { return now }
+
+	resp, err := v.Validate(context.Background(), signer.sign(t, podTokenClaims(now)))
+	if err == nil && resp.Status.Authenticated {
+		t.Error("expected token to be rejected when the issuer is unknown")
+	}
+}
+
+func TestJWKSValidatorUnknownKeyConcurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSigner{kid: "rsa", alg: "RS256", key: key}
+	unknownSigner := testSigner{kid: "unknown", alg: "RS256", key: otherKey}
+	server, fetches := runKeyServer(t, testIssuer, signer)
+
+	now := time.Unix(1700000000, 0)
+	v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Hour)
+	v.now = func() time.Time { return now }
+	if _, err := v.Validate(context.Background(), signer.sign(t, podTokenClaims(now))); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	token := unknownSigner.sign(t, podTokenClaims(now))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := v.Validate(context.Background(), token)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Status.Authenticated {
+				t.Error("expected token signed by an unknown key to be rejected")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(fetches); got != 2 {
+		t.Errorf("expected unknown key id to refresh the keys once but they were fetched %d times", got)
+	}
+}
+
+func TestJWKSValidatorKeysUnavailableBackoff(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	v := NewJWKSValidator(server.Client(), server.URL, []string{"concurrency-state-hook"}, time.Minute)
+	v.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate(context.Background(), "e30.e30.c2ln"); err == nil {
+			t.Fatal("expected an error when the keys can't be fetched")
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("expected the API server to be asked once but it was asked %d times", got)
+	}
+
+	now = now.Add(minKeyRefreshInterval + time.Second)
+	if _, err := v.Validate(context.Background(), "e30.e30.c2ln"); err == nil {
+		t.Fatal("expected an error when the keys can't be fetched")
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("expected the API server to be asked again after the interval but it was asked %d times", got)
+	}
+}
@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// FreezeResource and FreezeSubresource make up the virtual resource
+	// callers need RBAC access to, with the action ("pause" or "resume") as the verb.
+	FreezeResource    = "pods"
+	FreezeSubresource = "freeze"
+)
+
+type sarCacheEntry struct {
+	allowed bool
+	reason  string
+	expires time.Time
+}
+
+// SubjectAccessReviewAuthorizer authorizes callers by asking the API server
+// whether they may use the action as a verb on the pods/freeze subresource of
+// their pod, or of the target pod if the request names one. For example, a
+// service account bound to this Role may freeze any pod of its namespace:
+//
+//	rules:
+//	- apiGroups: [""]
+//	  resources: ["pods/freeze"]
+//	  verbs: ["pause", "resume"]
+//
+// Decisions are cached for TTL if allowed and NegativeTTL if denied.
+type SubjectAccessReviewAuthorizer struct {
+	Client      kubernetes.Interface
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]sarCacheEntry
+	now     func() time.Time
+}
+
+// NewSubjectAccessReviewAuthorizer returns a SubjectAccessReviewAuthorizer using the given client
+func NewSubjectAccessReviewAuthorizer(client kubernetes.Interface, ttl, negativeTTL time.Duration) *SubjectAccessReviewAuthorizer {
+	return &SubjectAccessReviewAuthorizer{
+		Client:      client,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		entries:     make(map[string]sarCacheEntry),
+		now:         time.Now,
+	}
+}
+
+// Authorize returns an *AuthorizationError if the SubjectAccessReview is denied
+func (a *SubjectAccessReviewAuthorizer) Authorize(ctx context.Context, caller *Caller, action string) error {
+	if caller.Namespace == "" {
+		return authzErrorf(http.StatusForbidden, CauseNotServiceAccount, "user %q is not a service account", caller.User.Username)
+	}
+
+	key := a.cacheKey(caller, action)
+	now := a.now()
+
+	a.mu.Lock()
+	entry, ok := a.entries[key]
+	if ok && now.After(entry.expires) {
+		delete(a.entries, key)
+		ok = false
+	}
+	a.mu.Unlock()
+
+	if !ok {
+		review, err := a.Client.AuthorizationV1().SubjectAccessReviews().Create(ctx, a.review(caller, action), metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		entry = sarCacheEntry{allowed: review.Status.Allowed && !review.Status.Denied, reason: review.Status.Reason}
+
+		ttl := a.NegativeTTL
+		if entry.allowed {
+			ttl = a.TTL
+		}
+		if ttl > 0 {
+			entry.expires = now.Add(ttl)
+			a.mu.Lock()
+			if len(a.entries) >= maxCacheEntries {
+				a.sweep(now)
+			}
+			a.entries[key] = entry
+			a.mu.Unlock()
+		}
+	}
+
+	if !entry.allowed {
+		namespace, name := reviewedPod(caller)
+		return authzErrorf(http.StatusForbidden, CauseAccessReviewDenied, "%s is not allowed to %s %s/%s/%s in %s: %s",
+			caller.User.Username, action, FreezeResource, name, FreezeSubresource, namespace, entry.reason)
+	}
+	return nil
+}
+
+func (a *SubjectAccessReviewAuthorizer) review(caller *Caller, action string) *authzv1.SubjectAccessReview {
+	extra := make(map[string]authzv1.ExtraValue, len(caller.User.Extra))
+	for k, v := range caller.User.Extra {
+		extra[k] = authzv1.ExtraValue(v)
+	}
+
+	namespace, name := reviewedPod(caller)
+	return &authzv1.SubjectAccessReview{
+		Spec: authzv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Namespace:   namespace,
+				Verb:        action,
+				Resource:    FreezeResource,
+				Subresource: FreezeSubresource,
+				Name:        name,
+			},
+			User:   caller.User.Username,
+			UID:    caller.User.UID,
+			Groups: caller.User.Groups,
+			Extra:  extra,
+		},
+	}
+}
+
+func (a *SubjectAccessReviewAuthorizer) cacheKey(caller *Caller, action string) string {
+	groups := append([]string(nil), caller.User.Groups...)
+	sort.Strings(groups)
+	namespace, name := reviewedPod(caller)
+	return strings.Join([]string{caller.User.Username, strings.Join(groups, ","), namespace, name, action}, "\x00")
+}
+
+// reviewedPod is the pod access is reviewed for, the target of the request if
+// it names one
+func reviewedPod(caller *Caller) (namespace, name string) {
+	if caller.Target != nil {
+		return caller.Target.Namespace, caller.Target.Name
+	}
+	return caller.Namespace, caller.PodName
+}
+
+// sweep removes expired entries, and if the cache is still full drops all of
+// them. It must be called with a.mu held.
+func (a *SubjectAccessReviewAuthorizer) sweep(now time.Time) {
+	for k, e := range a.entries {
+		if now.After(e.expires) {
+			delete(a.entries, k)
+		}
+	}
+	if len(a.entries) >= maxCacheEntries {
+		a.entries = make(map[string]sarCacheEntry)
+	}
+}
+
+// Authorizers is an Authorizer which requires all of its Authorizers to allow a request
+type Authorizers []Authorizer
+
+func (as Authorizers) Authorize(ctx context.Context, caller *Caller, action string) error {
+	for _, a := range as {
+		if err := a.Authorize(ctx, caller, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
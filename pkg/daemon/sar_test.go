@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestSubjectAccessReviewAuthorizer(t *testing.T) {
+	caller := &Caller{
+		PodUID:         "the-pod-uid",
+		PodName:        "the-pod",
+		Namespace:      "the-ns",
+		ServiceAccount: "the-sa",
+		User: authv1.UserInfo{
+			Username: "system:serviceaccount:the-ns:the-sa",
+			Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:the-ns"},
+			Extra: map[string]authv1.ExtraValue{
+				"authentication.kubernetes.io/pod-uid": {"the-pod-uid"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		allowed     bool
+		err         error
+		advance     time.Duration
+		expectErr   bool
+		expectCode  int
+		expectCalls int
+	}{{
+		name:        "allowed, cached",
+		allowed:     true,
+		advance:     time.Second,
+		expectCalls: 1,
+	}, {
+		name:        "allowed, cache expired",
+		allowed:     true,
+		advance:     2 * time.Minute,
+		expectCalls: 2,
+	}, {
+		name:        "denied, cached",
+		advance:     time.Second,
+		expectErr:   true,
+		expectCode:  403,
+		expectCalls: 1,
+	}, {
+		name:        "denied, cache expired",
+		advance:     10 * time.Second,
+		expectErr:   true,
+		expectCode:  403,
+		expectCalls: 2,
+	}, {
+		name:        "api error is not cached",
+		err:         errors.New("some error"),
+		expectErr:   true,
+		expectCalls: 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reviews []*authzv1.SubjectAccessReview
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				review := action.(clienttesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+				reviews = append(reviews, review)
+				if test.err != nil {
+					return true, nil, test.err
+				}
+				review.Status.Allowed = test.allowed
+				return true, review, nil
+			})
+
+			now := time.Unix(1000, 0)
+			authorizer := NewSubjectAccessReviewAuthorizer(client, time.Minute, 5*time.Second)
+			authorizer.now = func() time.Time { return now }
+
+			for i := 0; i < 2; i++ {
+				err := authorizer.Authorize(context.Background(), caller, "pause")
+				if (err != nil) != test.expectErr {
+					t.Fatalf("expected error %v but got %v", test.expectErr, err)
+				}
+				var authzErr *AuthorizationError
+				if test.expectCode != 0 && (!errors.As(err, &authzErr) || authzErr.Code != test.expectCode) {
+					t.Errorf("expected an authorization error with code %d but got %v", test.expectCode, err)
+				}
+				now = now.Add(test.advance)
+			}
+
+			if len(reviews) != test.expectCalls {
+				t.Fatalf("expected %d SubjectAccessReviews but got %d", test.expectCalls, len(reviews))
+			}
+
+			attrs := reviews[0].Spec.ResourceAttributes
+			if attrs.Namespace != "the-ns" || attrs.Name != "the-pod" || attrs.Verb != "pause" ||
+				attrs.Resource != FreezeResource || attrs.Subresource != FreezeSubresource {
+				t.Errorf("unexpected resource attributes %+v", attrs)
+			}
+			if got, want := reviews[0].Spec.User, caller.User.Username; got != want {
+				t.Errorf("expected user %q but got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizers(t *testing.T) {
+	var called []string
+	authorizer := func(name string, err error) Authorizer {
+		return AuthorizerFunc(func(context.Context, *Caller, string) error {
+			called = append(called, name)
+			return err
+		})
+	}
+
+	err := Authorizers{
+		authorizer("first", nil),
+		authorizer("second", errors.New("denied")),
+		authorizer("third", nil),
+	}.Authorize(context.Background(), &Caller{}, "pause")
+	if err == nil {
+		t.Error("expected an error from the second authorizer")
+	}
+	if len(called) != 2 {
+		t.Errorf("expected authorizers to stop at the first error but called %v", called)
+	}
+}
@@ -0,0 +1,79 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodRef names a pod other than the caller's for a request to act on. UID is
+// filled in once the pod was found on the daemon's node.
+type PodRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"-"`
+}
+
+// PodLookup finds the pods requests name as their target
+type PodLookup interface {
+	// PodUID returns the UID of the pod, or an *AuthorizationError if it is
+	// not running on the daemon's node
+	PodUID(ctx context.Context, namespace, name string) (string, error)
+}
+
+// NodePodLookup looks pods up through the API server, and only finds those
+// running on NodeName
+type NodePodLookup struct {
+	Client   kubernetes.Interface
+	NodeName string
+}
+
+func (l *NodePodLookup) PodUID(ctx context.Context, namespace, name string) (string, error) {
+	pod, err := l.Client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", authzErrorf(http.StatusNotFound, CausePodNotFound, "pod %s/%s not found", namespace, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if pod.Spec.NodeName != l.NodeName {
+		return "", authzErrorf(http.StatusMisdirectedRequest, CauseOtherNode, "pod %s/%s is on node %s, not %s", namespace, name, pod.Spec.NodeName, l.NodeName)
+	}
+	return string(pod.UID), nil
+}
+
+// authorizeCall authorizes the caller described by the user info to perform
+// the action on its own pod or, if target is not nil, on the target pod.
+// Targets are only accepted with both an authorizer, which must check them,
+// and a lookup to find them with.
+func authorizeCall(ctx context.Context, authorizer Authorizer, pods PodLookup, caller *Caller, target *PodRef, action string) error {
+	if target != nil {
+		if authorizer == nil || pods == nil {
+			return authzErrorf(http.StatusForbidden, CauseTargetNotAllowed, "acting on pod %s/%s is not allowed", target.Namespace, target.Name)
+		}
+		if target.Namespace == "" || target.Name == "" {
+			return authzErrorf(http.StatusBadRequest, CauseTargetInvalid, "target pod needs a namespace and a name")
+		}
+		caller.Target = &PodRef{Namespace: target.Namespace, Name: target.Name}
+	}
+
+	if authorizer != nil {
+		if err := authorizer.Authorize(ctx, caller, action); err != nil {
+			return err
+		}
+	}
+
+	// The pod is only looked up once the caller may act on it, so callers
+	// cannot find out which pods exist
+	if caller.Target != nil {
+		uid, err := pods.PodUID(ctx, caller.Target.Namespace, caller.Target.Name)
+		if err != nil {
+			return err
+		}
+		caller.Target.UID = uid
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	authv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"knative.dev/container-freezer/pkg/daemon"
+	ltesting "knative.dev/pkg/logging/testing"
+)
+
+func pod(namespace, name, uid, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(uid)},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+func TestNodePodLookup(t *testing.T) {
+	lookup := &daemon.NodePodLookup{
+		Client:   fake.NewSimpleClientset(pod("apps", "api-1", "api-1-uid", "node-a"), pod("apps", "api-2", "api-2-uid", "node-b")),
+		NodeName: "node-a",
+	}
+
+	tests := []struct {
+		name       string
+		pod        string
+		expectUID  string
+		expectCode int
+	}{{
+		name:      "on this node",
+		pod:       "api-1",
+		expectUID: "api-1-uid",
+	}, {
+		name:       "on another node",
+		pod:        "api-2",
+		expectCode: 421,
+	}, {
+		name:       "not found",
+		pod:        "api-3",
+		expectCode: 404,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			uid, err := lookup.PodUID(context.Background(), "apps", test.pod)
+			if test.expectCode == 0 {
+				if err != nil || uid != test.expectUID {
+					t.Errorf("expected UID %q but got %q, %v", test.expectUID, uid, err)
+				}
+				return
+			}
+
+			var authzErr *daemon.AuthorizationError
+			if !errors.As(err, &authzErr) || authzErr.Code != test.expectCode {
+				t.Errorf("expected code %d but got %v", test.expectCode, err)
+			}
+		})
+	}
+}
+
+func TestHandlerTargetPod(t *testing.T) {
+	// The admin service account may freeze the pods of the apps namespace
+	client := fake.NewSimpleClientset(pod("apps", "api-1", "api-1-uid", "node-a"), pod("other", "db-1", "db-1-uid", "node-a"))
+	var reviewed []authzv1.ResourceAttributes
+	client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authzv1.SubjectAccessReview)
+		attrs := *review.Spec.ResourceAttributes
+		reviewed = append(reviewed, attrs)
+		review.Status.Allowed = review.Spec.User == "system:serviceaccount:admin:freezer" && attrs.Namespace == "apps"
+		return true, review, nil
+	})
+	validator := daemon.TokenValidatorFunc(func(ctx context.Context, token string) (*authv1.TokenReview, error) {
+		return &authv1.TokenReview{
+			Status: authv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authv1.UserInfo{
+					Username: "system:serviceaccount:admin:freezer",
+					Extra: map[string]authv1.ExtraValue{
+						"authentication.kubernetes.io/pod-uid": {"admin-pod-uid"},
+					},
+				},
+			},
+		}, nil
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		lookup       bool
+		expectStatus int
+		expectFreeze string
+	}{{
+		name:         "own pod",
+		body:         `{ "action": "pause" }`,
+		lookup:       true,
+		expectStatus: 403,
+	}, {
+		name:         "pod in allowed namespace",
+		body:         `{ "action": "pause", "pod": { "namespace": "apps", "name": "api-1" } }`,
+		lookup:       true,
+		expectStatus: 200,
+		expectFreeze: "api-1-uid",
+	}, {
+		name:         "pod in other namespace",
+		body:         `{ "action": "pause", "pod": { "namespace": "other", "name": "db-1" } }`,
+		lookup:       true,
+		expectStatus: 403,
+	}, {
+		name:         "unknown pod in allowed namespace",
+		body:         `{ "action": "pause", "pod": { "namespace": "apps", "name": "api-2" } }`,
+		lookup:       true,
+		expectStatus: 404,
+	}, {
+		name:         "pod without namespace",
+		body:         `{ "action": "pause", "pod": { "name": "api-1" } }`,
+		lookup:       true,
+		expectStatus: 400,
+	}, {
+		name:         "other pods not enabled",
+		body:         `{ "action": "pause", "pod": { "namespace": "apps", "name": "api-1" } }`,
+		expectStatus: 403,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var froze string
+			handler := daemon.Handler{
+				Logger:     ltesting.TestLogger(t),
+				Validator:  validator,
+				Authorizer: daemon.NewSubjectAccessReviewAuthorizer(client, 0, 0),
+				Freezer: FreezeFunc(func(_ context.Context, podName string) error {
+					froze = podName
+					return nil
+				}),
+			}
+			if test.lookup {
+				handler.Pods = &daemon.NodePodLookup{Client: client, NodeName: "node-a"}
+			}
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/", bytes.NewBufferString(test.body))
+			req.Header.Set(daemon.TokenHeaderKey, "THE_TOKEN")
+			handler.ServeHTTP(resp, req)
+
+			if got, want := resp.Code, test.expectStatus; got != want {
+				t.Errorf("Expected response code %v but was %v", want, got)
+			}
+			if got, want := froze, test.expectFreeze; got != want {
+				t.Errorf("Expected frozen to be %q but was %q", want, got)
+			}
+		})
+	}
+
+	// Access to other pods is reviewed against them
+	if got := reviewed[1]; got.Namespace != "apps" || got.Name != "api-1" || got.Subresource != daemon.FreezeSubresource {
+		t.Errorf("Expected the review to be for pods/freeze of apps/api-1 but got %+v", got)
+	}
+}
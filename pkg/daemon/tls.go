@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CertificateReloader serves a certificate and, optionally, a client CA bundle
+// from files, reloading them when they change so that rotated certificates
+// are picked up without restarting the daemon.
+type CertificateReloader struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time
+}
+
+// NewCertificateReloader returns a CertificateReloader which has loaded the
+// given files. If clientCAFile is empty client certificates are not requested.
+func NewCertificateReloader(certFile, keyFile, clientCAFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: clientCAFile,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reloads the files if any of them changed since they were last
+// loaded. It returns whether anything was reloaded. If the new files are
+// invalid the previously loaded ones are kept.
+func (r *CertificateReloader) Reload() (bool, error) {
+	files := [3]string{r.CertFile, r.KeyFile, r.ClientCAFile}
+	var modTimes [3]time.Time
+	for i, f := range files {
+		if f == "" {
+			continue
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTimes == r.modTimes
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("loading certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if r.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("loading client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, errors.New("loading client CA: no certificates found")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	return true, nil
+}
+
+// Watch polls the files for changes every interval until ctx is done.
+func (r *CertificateReloader) Watch(ctx context.Context, interval time.Duration, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				logger.Errorf("Reloading TLS certificates failed: %v", err)
+			} else if reloaded {
+				logger.Info("Reloaded TLS certificates")
+			}
+		}
+	}
+}
+
+// TLSConfig returns a tls.Config which always uses the most recently loaded
+// certificate, and requires a client certificate signed by the client CA if
+// one is configured. nextProtos are the application protocols negotiated with
+// ALPN, such as "h2" which gRPC clients require.
+func (r *CertificateReloader) TLSConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   nextProtos,
+			}
+			if r.clientCAs != nil {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = r.clientCAs
+			}
+			return cfg, nil
+		},
+	}
+}
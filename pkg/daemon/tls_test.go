@@ -0,0 +1,201 @@
+package daemon_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"knative.dev/container-freezer/pkg/daemon"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "freezer"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startTLSServer(t *testing.T, reloader *daemon.CertificateReloader) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func servedSerial(t *testing.T, url string, roots *x509.CertPool, clientCert *tls.Certificate) (int64, error) {
+	t.Helper()
+	cfg := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if clientCert != nil {
+		cfg.Certificates = []tls.Certificate{*clientCert}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestCertificateReloader(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	mtime := time.Now().Add(-time.Minute)
+
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, mtime)
+	writeFile(t, keyFile, key, mtime)
+
+	reloader, err := daemon.NewCertificateReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	server := startTLSServer(t, reloader)
+
+	if serial, err := servedSerial(t, server.URL, roots, nil); err != nil || serial != 10 {
+		t.Fatalf("expected certificate 10 to be served but got %d (%v)", serial, err)
+	}
+
+	if reloaded, err := reloader.Reload(); err != nil || reloaded {
+		t.Errorf("expected unchanged files not to be reloaded, got reloaded=%v err=%v", reloaded, err)
+	}
+
+	cert, key = ca.issue(t, 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, mtime.Add(time.Second))
+	writeFile(t, keyFile, key, mtime.Add(time.Second))
+	if reloaded, err := reloader.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected rotated files to be reloaded, got reloaded=%v err=%v", reloaded, err)
+	}
+
+	if serial, err := servedSerial(t, server.URL, roots, nil); err != nil || serial != 11 {
+		t.Errorf("expected certificate 11 to be served after rotation but got %d (%v)", serial, err)
+	}
+
+	writeFile(t, certFile, []byte("not a certificate"), mtime.Add(2*time.Second))
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("expected an error reloading an invalid certificate")
+	}
+	if serial, err := servedSerial(t, server.URL, roots, nil); err != nil || serial != 11 {
+		t.Errorf("expected the previous certificate to still be served but got %d (%v)", serial, err)
+	}
+}
+
+func TestCertificateReloaderClientAuth(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	mtime := time.Now()
+
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, mtime)
+	writeFile(t, keyFile, key, mtime)
+	writeFile(t, caFile, ca.pem, mtime)
+
+	reloader, err := daemon.NewCertificateReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	server := startTLSServer(t, reloader)
+
+	if _, err := servedSerial(t, server.URL, roots, nil); err == nil {
+		t.Error("expected a request without a client certificate to fail")
+	}
+
+	clientCertPEM, clientKeyPEM := ca.issue(t, 20, x509.ExtKeyUsageClientAuth)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := servedSerial(t, server.URL, roots, &clientCert); err != nil {
+		t.Errorf("expected a request with a client certificate to succeed but got %v", err)
+	}
+
+	otherCA := newTestCA(t)
+	otherCertPEM, otherKeyPEM := otherCA.issue(t, 30, x509.ExtKeyUsageClientAuth)
+	otherCert, err := tls.X509KeyPair(otherCertPEM, otherKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := servedSerial(t, server.URL, roots, &otherCert); err == nil {
+		t.Error("expected a request with an untrusted client certificate to fail")
+	}
+}
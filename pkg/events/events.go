@@ -0,0 +1,188 @@
+// Package events sends CloudEvents when pods are frozen and thawed.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"knative.dev/container-freezer/pkg/freeze"
+)
+
+const (
+	TypeFrozen = "dev.knative.freezer.pod.frozen"
+	TypeThawed = "dev.knative.freezer.pod.thawed"
+	TypeFailed = "dev.knative.freezer.pod.failed"
+)
+
+const (
+	// queueSize is how many events may wait for delivery, later events are dropped
+	queueSize = 1024
+
+	defaultRetries = 5
+	defaultBackoff = 500 * time.Millisecond
+)
+
+// Data is the payload of the events
+type Data struct {
+	PodUID    string            `json:"podUID"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Action is the operation which made the transition, "freeze" or "thaw"
+	Action          string    `json:"action"`
+	StartedAt       time.Time `json:"startedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	// FrozenSeconds is how long the pod had been frozen, set when it is thawed
+	FrozenSeconds float64 `json:"frozenSeconds,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Emitter delivers events about transitions to a sink in the background
+type Emitter struct {
+	// Sink is the URL events are posted to
+	Sink string
+	// Source is the CloudEvents source of the events
+	Source string
+	Client *http.Client
+	// Resolver is optional, if set events carry the namespace, name and
+	// Knative labels of the pod
+	Resolver PodResolver
+	// Retries is how many times delivery is retried, waiting Backoff before
+	// the first retry and doubling the wait every time
+	Retries int
+	Backoff time.Duration
+	Logger  *zap.SugaredLogger
+
+	queue chan freeze.Transition
+}
+
+// NewEmitter returns an emitter posting events to sink
+func NewEmitter(sink, source string, resolver PodResolver, logger *zap.SugaredLogger) *Emitter {
+	return &Emitter{
+		Sink:     sink,
+		Source:   source,
+		Client:   http.DefaultClient,
+		Resolver: resolver,
+		Retries:  defaultRetries,
+		Backoff:  defaultBackoff,
+		Logger:   logger,
+		queue:    make(chan freeze.Transition, queueSize),
+	}
+}
+
+// Emit queues an event for the transition. It never blocks, if the queue is
+// full the event is dropped.
+func (e *Emitter) Emit(t freeze.Transition) {
+	select {
+	case e.queue <- t:
+	default:
+		e.Logger.Warnf("event queue full, dropping %s event for pod %s", t.Action, t.PodUID)
+	}
+}
+
+// Run delivers queued events until ctx is done. An event being sent when ctx
+// is done is dropped quietly.
+func (e *Emitter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-e.queue:
+			if err := e.send(ctx, t); err != nil && ctx.Err() == nil {
+				e.Logger.Errorf("sending %s event for pod %s failed: %v", t.Action, t.PodUID, err)
+			}
+		}
+	}
+}
+
+func (e *Emitter) send(ctx context.Context, t freeze.Transition) error {
+	eventType, data := e.event(ctx, t)
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	id := uuid.NewString()
+
+	backoff := e.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(ctx, id, eventType, t.Started, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= e.Retries {
+			return err
+		}
+		e.Logger.Debugf("sending event %s failed, retrying in %v: %v", id, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the event in the binary content mode of the HTTP binding. It
+// reports whether a failure is worth retrying, which it is not once ctx is
+// done.
+func (e *Emitter) post(ctx context.Context, id, eventType string, at time.Time, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Sink, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", id)
+	req.Header.Set("Ce-Type", eventType)
+	req.Header.Set("Ce-Source", e.Source)
+	req.Header.Set("Ce-Time", at.UTC().Format(time.RFC3339Nano))
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("sink returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("sink returned %s", resp.Status)
+	}
+}
+
+func (e *Emitter) event(ctx context.Context, t freeze.Transition) (string, Data) {
+	data := Data{
+		PodUID:          t.PodUID,
+		Action:          t.Action,
+		StartedAt:       t.Started,
+		DurationSeconds: t.Duration.Seconds(),
+		FrozenSeconds:   t.FrozenFor.Seconds(),
+	}
+	if e.Resolver != nil {
+		if pod, err := e.Resolver.Resolve(ctx, t.PodUID); err != nil {
+			e.Logger.Warnf("resolving pod %s failed: %v", t.PodUID, err)
+		} else {
+			data.Namespace, data.Name, data.Labels = pod.Namespace, pod.Name, pod.Labels
+		}
+	}
+
+	switch {
+	case t.Err != nil:
+		data.Error = t.Err.Error()
+		return TypeFailed, data
+	case t.Action == freeze.ActionThaw:
+		return TypeThawed, data
+	default:
+		return TypeFrozen, data
+	}
+}
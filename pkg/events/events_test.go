@@ -0,0 +1,193 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	ltesting "knative.dev/pkg/logging/testing"
+
+	"knative.dev/container-freezer/pkg/freeze"
+)
+
+type receivedEvent struct {
+	header http.Header
+	data   Data
+}
+
+// receiver answers with the given status codes in turn, and 200 once they run out
+type receiver struct {
+	mu     sync.Mutex
+	codes  []int
+	events []receivedEvent
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var data Data
+	json.NewDecoder(req.Body).Decode(&data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, receivedEvent{header: req.Header, data: data})
+	code := http.StatusOK
+	if len(r.codes) > 0 {
+		code, r.codes = r.codes[0], r.codes[1:]
+	}
+	w.WriteHeader(code)
+}
+
+func TestSend(t *testing.T) {
+	started := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		transition freeze.Transition
+		codes      []int
+		wantErr    bool
+		wantType   string
+		wantSends  int
+	}{{
+		name:       "frozen",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionFreeze, Started: started, Duration: time.Second},
+		wantType:   TypeFrozen,
+		wantSends:  1,
+	}, {
+		name:       "thawed",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionThaw, Started: started, FrozenFor: time.Minute},
+		wantType:   TypeThawed,
+		wantSends:  1,
+	}, {
+		name:       "failed",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionThaw, Started: started, Err: errors.New("boom")},
+		wantType:   TypeFailed,
+		wantSends:  1,
+	}, {
+		name:       "retried",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionFreeze, Started: started},
+		codes:      []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+		wantType:   TypeFrozen,
+		wantSends:  3,
+	}, {
+		name:       "retries exhausted",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionFreeze, Started: started},
+		codes:      []int{500, 500, 500, 500},
+		wantErr:    true,
+		wantType:   TypeFrozen,
+		wantSends:  3,
+	}, {
+		name:       "rejected",
+		transition: freeze.Transition{PodUID: "123", Action: freeze.ActionFreeze, Started: started},
+		codes:      []int{http.StatusBadRequest},
+		wantErr:    true,
+		wantType:   TypeFrozen,
+		wantSends:  1,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			rcv := &receiver{codes: c.codes}
+			sink := httptest.NewServer(rcv)
+			defer sink.Close()
+
+			e := NewEmitter(sink.URL, "/freezer/node-1", nil, ltesting.TestLogger(t))
+			e.Retries = 2
+			e.Backoff = time.Millisecond
+
+			err := e.send(context.Background(), c.transition)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if len(rcv.events) != c.wantSends {
+				t.Fatalf("expected %d deliveries but got %d", c.wantSends, len(rcv.events))
+			}
+
+			got := rcv.events[0]
+			if typ := got.header.Get("Ce-Type"); typ != c.wantType {
+				t.Errorf("expected type %s but got %s", c.wantType, typ)
+			}
+			if src := got.header.Get("Ce-Source"); src != "/freezer/node-1" {
+				t.Errorf("expected source /freezer/node-1 but got %s", src)
+			}
+			if got.header.Get("Ce-Id") != rcv.events[len(rcv.events)-1].header.Get("Ce-Id") {
+				t.Error("expected retries to keep the event id")
+			}
+			if got.data.PodUID != "123" || got.data.Action != c.transition.Action || !got.data.StartedAt.Equal(started) {
+				t.Errorf("unexpected event data %+v", got.data)
+			}
+			if got.data.FrozenSeconds != c.transition.FrozenFor.Seconds() {
+				t.Errorf("expected frozen seconds %v but got %v", c.transition.FrozenFor.Seconds(), got.data.FrozenSeconds)
+			}
+		})
+	}
+}
+
+func TestRunWithResolver(t *testing.T) {
+	rcv := &receiver{}
+	sink := httptest.NewServer(rcv)
+	defer sink.Close()
+
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "hello-00001-deployment-abc",
+			UID:       types.UID("123"),
+			Labels: map[string]string{
+				"serving.knative.dev/revision": "hello-00001",
+				"app":                          "hello",
+			},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+	})
+	resolver := &NodePodResolver{Client: client, NodeName: "node-1"}
+
+	e := NewEmitter(sink.URL, "/freezer/node-1", resolver, ltesting.TestLogger(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	e.Emit(freeze.Transition{PodUID: "123", Action: freeze.ActionFreeze, Started: time.Now()})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rcv.mu.Lock()
+		n := len(rcv.events)
+		rcv.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rcv.mu.Lock()
+	data := rcv.events[0].data
+	rcv.mu.Unlock()
+	if data.Namespace != "default" || data.Name != "hello-00001-deployment-abc" {
+		t.Errorf("expected the pod to be resolved but got %+v", data)
+	}
+	if want := map[string]string{"serving.knative.dev/revision": "hello-00001"}; !reflect.DeepEqual(data.Labels, want) {
+		t.Errorf("expected labels %v but got %v", want, data.Labels)
+	}
+
+	if _, err := resolver.Resolve(ctx, "456"); err == nil {
+		t.Error("expected resolving an unknown pod to fail")
+	}
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// knativeLabelPrefix selects the labels copied into events, such as the
+// service, configuration and revision of the pod
+const knativeLabelPrefix = "serving.knative.dev/"
+
+// Pod is what events say about a pod besides its UID
+type Pod struct {
+	Namespace string
+	Name      string
+	Labels    map[string]string
+}
+
+// PodResolver looks pods up by UID
+type PodResolver interface {
+	Resolve(ctx context.Context, podUID string) (*Pod, error)
+}
+
+// NodePodResolver resolves pods running on a node. It lists the pods of the
+// node when asked for a pod it has not seen yet, and remembers them.
+type NodePodResolver struct {
+	Client   kubernetes.Interface
+	NodeName string
+
+	mu   sync.Mutex
+	pods map[string]*Pod
+}
+
+func (r *NodePodResolver) Resolve(ctx context.Context, podUID string) (*Pod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pod, ok := r.pods[podUID]; ok {
+		return pod, nil
+	}
+
+	list, err := r.Client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", r.NodeName).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Start over, so pods which are gone are forgotten
+	r.pods = make(map[string]*Pod, len(list.Items))
+	for _, p := range list.Items {
+		labels := make(map[string]string)
+		for k, v := range p.Labels {
+			if strings.HasPrefix(k, knativeLabelPrefix) {
+				labels[k] = v
+			}
+		}
+		r.pods[string(p.UID)] = &Pod{Namespace: p.Namespace, Name: p.Name, Labels: labels}
+	}
+
+	pod, ok := r.pods[podUID]
+	if !ok {
+		return nil, fmt.Errorf("pod %s not found on node %s", podUID, r.NodeName)
+	}
+	return pod, nil
+}
@@ -0,0 +1,129 @@
+package freeze
+
+import (
+	"context"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// ActionAudit is the action of mismatches found by the audit in RecentErrors
+const ActionAudit = "audit"
+
+// auditTarget is a pod the audit checks, as it was when the audit started
+type auditTarget struct {
+	podUID     string
+	st         *podState
+	latest     uint64
+	containers []string
+	want       common.ContainerState
+}
+
+// Audit compares the state of containers with what the freezer did to them:
+// the containers of pods it froze must be paused, and those of pods it thawed
+// must not be, whoever paused or resumed them instead. Thawed pods are only
+// remembered while they are kept for their minimum running time. Pods being
+// frozen or thawed, or throttled, are skipped. Mismatches are logged, recorded
+// in RecentErrors and returned.
+func (c *ContainerRuntimeImpl) Audit(ctx context.Context) []StateMismatchError {
+	var mismatches []StateMismatchError
+	for _, target := range c.auditTargets(ctx) {
+		for _, ctr := range target.containers {
+			var state common.ContainerState
+			var reported bool
+			_, err := call(ctx, c.timeouts.List, func(ctx context.Context) (err error) {
+				state, reported, err = c.status(ctx, ctr)
+				return err
+			})
+			if err != nil {
+				c.getLogger().Warnf("auditing %s of pod %s: %v", ctr, target.podUID, err)
+				continue
+			}
+			if !reported || !target.mismatch(state) || !c.unchanged(target, ctr) {
+				continue
+			}
+
+			mismatch := StateMismatchError{Container: ctr, Want: target.want, Got: state}
+			if target.want == common.StatePaused {
+				recordStateMismatch(mismatchResumedExternally)
+			} else {
+				recordStateMismatch(mismatchPausedExternally)
+			}
+			c.getLogger().Warnw("container changed behind the freezer's back", "pod", target.podUID, "container", ctr, "want", target.want, "got", state)
+			c.recordError(target.podUID, ActionAudit, &mismatch)
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+// RunAudit audits the pods every interval until ctx is done
+func (c *ContainerRuntimeImpl) RunAudit(ctx context.Context, interval time.Duration) {
+	clk := c.getClock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(interval):
+			c.Audit(ctx)
+		}
+	}
+}
+
+// auditTargets returns the pods to audit with the containers to check
+func (c *ContainerRuntimeImpl) auditTargets(ctx context.Context) []auditTarget {
+	c.mu.Lock()
+	var targets, thawed []auditTarget
+	for name, st := range c.pods {
+		if st.busy != nil || st.mode == ModeThrottle {
+			continue
+		}
+		target := auditTarget{podUID: name, st: st, latest: st.latest}
+		if len(st.paused) > 0 {
+			target.containers = append([]string(nil), st.paused...)
+			target.want = common.StatePaused
+			targets = append(targets, target)
+		} else if st.tracked || !st.thawedAt.IsZero() {
+			target.want = common.StateRunning
+			thawed = append(thawed, target)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, target := range thawed {
+		ids, err := c.list(ctx, target.podUID)
+		if err != nil {
+			// The pod is likely gone
+			continue
+		}
+		target.containers = ids
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// mismatch reports whether state is not what the target expects, a
+// container which exited matches either way
+func (t auditTarget) mismatch(state common.ContainerState) bool {
+	switch state {
+	case common.StateStopped, common.StateUnknown, t.want:
+		return false
+	case common.StatePausing:
+		return t.want != common.StatePaused
+	default:
+		return true
+	}
+}
+
+// unchanged reports whether no operation touched the pod of the target since
+// the audit started, so that a mismatch is not just a freeze or thaw racing
+// with the audit
+func (c *ContainerRuntimeImpl) unchanged(target auditTarget, ctr string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.pods[target.podUID]
+	if st != target.st || st.busy != nil || st.latest != target.latest {
+		return false
+	}
+	return contains(st.paused, ctr) == (target.want == common.StatePaused)
+}
@@ -0,0 +1,44 @@
+package freeze
+
+import (
+	"knative.dev/container-freezer/pkg/freeze/containerd"
+	"knative.dev/container-freezer/pkg/freeze/crio"
+)
+
+const (
+	runtimeTypeContainerd = "containerd"
+	runtimeTypeCrio       = "crio"
+)
+
+// The backends built into the freezer
+func init() {
+	Register(runtimeTypeContainerd, func(opts BackendOptions) (CRI, error) {
+		var c *containerd.ContainerdCRI
+		var err error
+		if opts.Address == "" {
+			c, err = containerd.NewContainerdProvider()
+		} else {
+			c, err = containerd.NewContainerdProviderAt(opts.Address)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.SetStateDir(opts.StateDir)
+		return c, nil
+	}, Capabilities{Checkpoint: true, Throttle: true, Status: true})
+
+	Register(runtimeTypeCrio, func(opts BackendOptions) (CRI, error) {
+		var c *crio.CrioCRI
+		var err error
+		if opts.Address == "" {
+			c, err = crio.NewCrioProvider()
+		} else {
+			c, err = crio.NewCrioProviderAt(opts.Address)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.SetStateDir(opts.StateDir)
+		return c, nil
+	}, Capabilities{Throttle: true, Status: true})
+}
@@ -0,0 +1,181 @@
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// DefaultProcRoot is where the proc filesystem is usually mounted
+const DefaultProcRoot = "/proc"
+
+// errContainerNotFound is returned when no cgroup of the container exists
+var errContainerNotFound = errors.New("cgroup of container not found")
+
+// Freezer pauses containers by writing to their cgroup.freeze file, as runc
+// does on cgroup v2, without going through the container runtime. The runtime
+// keeps reporting frozen containers as running.
+type Freezer struct {
+	// Root is where the cgroup v2 hierarchy is mounted
+	Root string
+	// ProcRoot is where the host's proc filesystem is mounted
+	ProcRoot string
+}
+
+// NewFreezer returns a freezer for the hierarchy mounted at root
+func NewFreezer(root string) *Freezer {
+	return &Freezer{Root: root, ProcRoot: DefaultProcRoot}
+}
+
+// Pause freezes the cgroup of the container. The kernel freezes its tasks in
+// the background, Status reports when it is done.
+func (f *Freezer) Pause(ctx context.Context, containerID string) error {
+	return f.write(containerID, "1")
+}
+
+// Resume thaws the cgroup of the container
+func (f *Freezer) Resume(ctx context.Context, containerID string) error {
+	return f.write(containerID, "0")
+}
+
+// Status reports whether the cgroup of the container is frozen, pausing while
+// the kernel has not frozen all of its tasks yet, and the container as stopped
+// once its cgroup is gone
+func (f *Freezer) Status(ctx context.Context, containerID string) (common.ContainerState, error) {
+	dir, err := f.containerDir(containerID)
+	if errors.Is(err, errContainerNotFound) {
+		return common.StateStopped, nil
+	}
+	if err != nil {
+		return common.StateUnknown, err
+	}
+
+	events, err := readKeys(filepath.Join(dir, "cgroup.events"))
+	if err != nil {
+		return common.StateUnknown, err
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	if err != nil {
+		return common.StateUnknown, err
+	}
+
+	frozen, ok := events["frozen"]
+	switch {
+	case !ok:
+		return common.StateUnknown, fmt.Errorf("%s has no frozen state in cgroup.events", containerID)
+	case frozen == "1":
+		return common.StatePaused, nil
+	case strings.TrimSpace(string(want)) == "1":
+		return common.StatePausing, nil
+	default:
+		return common.StateRunning, nil
+	}
+}
+
+// StuckProcesses returns the processes of the container in uninterruptible
+// sleep, which keep its cgroup from being frozen. It needs the daemon to see
+// the processes of the host under ProcRoot.
+func (f *Freezer) StuckProcesses(ctx context.Context, containerID string) ([]int, error) {
+	dir, err := f.containerDir(containerID)
+	if err != nil {
+		return nil, err
+	}
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	procRoot := f.ProcRoot
+	if procRoot == "" {
+		procRoot = DefaultProcRoot
+	}
+	var stuck []int
+	for _, field := range strings.Fields(string(procs)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil || pid == 0 {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join(procRoot, field, "stat"))
+		if err != nil {
+			// The process exited, or is not visible from here
+			continue
+		}
+		// The state follows the command, which is in parentheses and may
+		// itself hold spaces and parentheses
+		if i := strings.LastIndexByte(string(stat), ')'); i >= 0 && strings.HasPrefix(string(stat[i+1:]), " D") {
+			stuck = append(stuck, pid)
+		}
+	}
+	return stuck, nil
+}
+
+func (f *Freezer) write(containerID, value string) error {
+	dir, err := f.containerDir(containerID)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte(value), 0)
+}
+
+// containerDir finds the cgroup of the container under the kubepods
+// hierarchy, which holds levels for the QoS class and the pod. The cgroups of
+// CRI-O's conmon processes carry the container ID too and are skipped.
+func (f *Freezer) containerDir(containerID string) (string, error) {
+	if _, err := os.Stat(filepath.Join(f.Root, "cgroup.controllers")); err != nil {
+		return "", ErrUnsupported
+	}
+
+	for _, kubepods := range kubepodsDirs {
+		root := filepath.Join(f.Root, kubepods)
+		var found string
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != root && strings.Contains(d.Name(), containerID) && !strings.Contains(d.Name(), "conmon") {
+				found = path
+				return errFound
+			}
+			if strings.Count(strings.TrimPrefix(path, root), string(filepath.Separator)) >= 3 {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil && err != errFound && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if found != "" {
+			return found, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", errContainerNotFound, containerID)
+}
+
+// readKeys reads a flat keyed file such as cgroup.events
+func readKeys(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), " "); ok {
+			keys[key] = value
+		}
+	}
+	return keys, scanner.Err()
+}
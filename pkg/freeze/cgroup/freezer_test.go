@@ -0,0 +1,164 @@
+package cgroup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// fakeFreezerFS writes a cgroup v2 hierarchy holding the given container
+// cgroups, which are not frozen
+func fakeFreezerFS(t *testing.T, ctrDirs ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, ctrDir := range ctrDirs {
+		dir := filepath.Join(root, ctrDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range map[string]string{
+			"cgroup.freeze": "0\n",
+			"cgroup.events": "populated 1\nfrozen 0\n",
+		} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestFreezer(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctrDirs []string
+		wantDir string
+		wantErr bool
+	}{{
+		name:    "systemd driver",
+		ctrDirs: []string{"kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234_abcd.slice/cri-containerd-ctr1.scope"},
+		wantDir: "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234_abcd.slice/cri-containerd-ctr1.scope",
+	}, {
+		name: "skips conmon",
+		ctrDirs: []string{
+			"kubepods.slice/kubepods-pod1234_abcd.slice/crio-conmon-ctr1.scope",
+			"kubepods.slice/kubepods-pod1234_abcd.slice/crio-ctr1.scope",
+		},
+		wantDir: "kubepods.slice/kubepods-pod1234_abcd.slice/crio-ctr1.scope",
+	}, {
+		name:    "cgroupfs driver",
+		ctrDirs: []string{"kubepods/besteffort/pod1234-abcd/ctr1"},
+		wantDir: "kubepods/besteffort/pod1234-abcd/ctr1",
+	}, {
+		name:    "container not found",
+		ctrDirs: []string{"kubepods/besteffort/pod1234-abcd/ctr2"},
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			root := fakeFreezerFS(t, c.ctrDirs...)
+			f := NewFreezer(root)
+
+			err := f.Pause(context.Background(), "ctr1")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr {
+				return
+			}
+			assertFreeze(t, filepath.Join(root, c.wantDir), "1")
+
+			if err := f.Resume(context.Background(), "ctr1"); err != nil {
+				t.Fatalf("expected resume to succeed but failed: %v", err)
+			}
+			assertFreeze(t, filepath.Join(root, c.wantDir), "0")
+		})
+	}
+}
+
+func TestFreezerStatus(t *testing.T) {
+	root := fakeFreezerFS(t, "kubepods/pod1234/ctr1")
+	f := NewFreezer(root)
+
+	if state, err := f.Status(context.Background(), "ctr1"); err != nil || state != common.StateRunning {
+		t.Errorf("expected ctr1 to be running but got %s, %v", state, err)
+	}
+
+	events := filepath.Join(root, "kubepods/pod1234/ctr1/cgroup.events")
+	if err := os.WriteFile(events, []byte("populated 1\nfrozen 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := f.Status(context.Background(), "ctr1"); err != nil || state != common.StatePaused {
+		t.Errorf("expected ctr1 to be paused but got %s, %v", state, err)
+	}
+
+	freeze := filepath.Join(root, "kubepods/pod1234/ctr1/cgroup.freeze")
+	if err := os.WriteFile(freeze, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(events, []byte("populated 1\nfrozen 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := f.Status(context.Background(), "ctr1"); err != nil || state != common.StatePausing {
+		t.Errorf("expected ctr1 to be pausing but got %s, %v", state, err)
+	}
+
+	if state, err := f.Status(context.Background(), "ctr2"); err != nil || state != common.StateStopped {
+		t.Errorf("expected a container without a cgroup to be stopped but got %s, %v", state, err)
+	}
+}
+
+func TestStuckProcesses(t *testing.T) {
+	root := fakeFreezerFS(t, "kubepods/pod1234/ctr1")
+	if err := os.WriteFile(filepath.Join(root, "kubepods/pod1234/ctr1/cgroup.procs"), []byte("10\n11\n12\n13\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	procRoot := t.TempDir()
+	for pid, stat := range map[string]string{
+		"10": "10 (app) S 1 10 10 0",
+		"11": "11 (fuse worker) D 1 10 10 0",
+		"12": "12 (odd) D) R 1 10 10 0",
+		// 13 exited
+	} {
+		if err := os.MkdirAll(filepath.Join(procRoot, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procRoot, pid, "stat"), []byte(stat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &Freezer{Root: root, ProcRoot: procRoot}
+	pids, err := f.StuckProcesses(context.Background(), "ctr1")
+	if err != nil {
+		t.Fatalf("expected to find stuck processes but got %v", err)
+	}
+	if want := []int{11}; !reflect.DeepEqual(pids, want) {
+		t.Errorf("expected stuck pids %v but got %v", want, pids)
+	}
+}
+
+func TestFreezerCgroupV1(t *testing.T) {
+	if err := NewFreezer(t.TempDir()).Pause(context.Background(), "ctr1"); err != ErrUnsupported {
+		t.Errorf("expected %v but got %v", ErrUnsupported, err)
+	}
+}
+
+func assertFreeze(t *testing.T, dir, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected cgroup.freeze to be %q but got %q", want, got)
+	}
+}
@@ -0,0 +1,180 @@
+// Package cgroup pushes the memory of frozen containers out, and freezes
+// containers without going through the runtime, through cgroup v2.
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const DefaultRoot = "/sys/fs/cgroup"
+
+// ErrUnsupported is returned when the node does not use cgroup v2
+var ErrUnsupported = errors.New("the node does not use the unified cgroup v2 hierarchy")
+
+// errFound stops walking the hierarchy once the pod is found
+var errFound = errors.New("found")
+
+// kubepodsDirs are where the kubelet puts pod cgroups with the systemd and
+// the cgroupfs cgroup drivers
+var kubepodsDirs = []string{"kubepods.slice", "kubepods"}
+
+// Reclaimer reclaims the memory of containers by writing to memory.reclaim,
+// which pushes anonymous memory to swap or zswap and drops page cache
+type Reclaimer struct {
+	// Root is where the cgroup v2 hierarchy is mounted
+	Root string
+
+	// writeFile is replaced in tests to stand in for the kernel
+	writeFile func(name string, data []byte, perm os.FileMode) error
+}
+
+// NewReclaimer returns a reclaimer for the hierarchy mounted at root
+func NewReclaimer(root string) *Reclaimer {
+	return &Reclaimer{Root: root}
+}
+
+// Reclaim reclaims as much memory of the containers of the pod as it can and
+// returns how many bytes were reclaimed
+func (r *Reclaimer) Reclaim(ctx context.Context, podUID string, containerIDs []string) (int64, error) {
+	if _, err := os.Stat(filepath.Join(r.Root, "cgroup.controllers")); err != nil {
+		return 0, ErrUnsupported
+	}
+	podDir, err := r.podDir(podUID)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, id := range containerIDs {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		dir, err := containerDir(podDir, id)
+		if err != nil {
+			return total, err
+		}
+		reclaimed, err := r.reclaim(ctx, dir)
+		total += reclaimed
+		if err != nil {
+			return total, fmt.Errorf("%s not reclaimed: %w", id, err)
+		}
+	}
+	return total, nil
+}
+
+// podDir finds the cgroup of the pod under the kubepods hierarchy, which
+// holds a level for the QoS class unless the pod is guaranteed
+func (r *Reclaimer) podDir(podUID string) (string, error) {
+	names := []string{"pod" + podUID, "pod" + strings.ReplaceAll(podUID, "-", "_") + ".slice"}
+
+	for _, kubepods := range kubepodsDirs {
+		root := filepath.Join(r.Root, kubepods)
+		var found string
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			for _, name := range names {
+				if d.Name() == name || strings.HasSuffix(d.Name(), "-"+name) {
+					found = path
+					return errFound
+				}
+			}
+			if strings.Count(strings.TrimPrefix(path, root), string(filepath.Separator)) >= 2 {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil && err != errFound && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if found != "" {
+			return found, nil
+		}
+	}
+	return "", fmt.Errorf("cgroup of pod %s not found", podUID)
+}
+
+// containerDir finds the cgroup of the container in the cgroup of its pod,
+// which is named after the container ID with a runtime specific decoration
+func containerDir(podDir, containerID string) (string, error) {
+	entries, err := os.ReadDir(podDir)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.IsDir() && strings.Contains(e.Name(), containerID) {
+			return filepath.Join(podDir, e.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("cgroup of container %s not found", containerID)
+}
+
+// reclaimChunk is how much memory is asked for per write to memory.reclaim,
+// so that a reclaim can be stopped between writes rather than blocking until
+// all memory of a large container is reclaimed
+const reclaimChunk = 64 << 20
+
+// reclaim asks the kernel to reclaim all memory charged to the cgroup, until
+// ctx is done
+func (r *Reclaimer) reclaim(ctx context.Context, dir string) (int64, error) {
+	before, err := readInt(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0, err
+	}
+	if before == 0 {
+		return 0, nil
+	}
+
+	// The kernel gives up with EAGAIN when it cannot reclaim everything
+	// asked for, which is expected for memory which cannot be swapped.
+	writeFile := r.writeFile
+	if writeFile == nil {
+		writeFile = os.WriteFile
+	}
+	var stopped error
+	for left := before; left > 0; left -= reclaimChunk {
+		if stopped = ctx.Err(); stopped != nil {
+			break
+		}
+		chunk := left
+		if chunk > reclaimChunk {
+			chunk = reclaimChunk
+		}
+		err = writeFile(filepath.Join(dir, "memory.reclaim"), []byte(strconv.FormatInt(chunk, 10)), 0)
+		if errors.Is(err, syscall.EAGAIN) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	after, err := readInt(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0, err
+	}
+	if after > before {
+		return 0, stopped
+	}
+	return before - after, stopped
+}
+
+func readInt(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
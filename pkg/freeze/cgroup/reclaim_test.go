@@ -0,0 +1,154 @@
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// fakeCgroupFS writes a cgroup v2 hierarchy holding a single container using
+// current bytes of memory
+func fakeCgroupFS(t *testing.T, ctrDir string, current int64) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, ctrDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(root, "cgroup.controllers"): "cpu memory",
+		filepath.Join(dir, "memory.current"):      strconv.FormatInt(current, 10) + "\n",
+		filepath.Join(dir, "memory.reclaim"):      "",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// fakeKernel stands in for the kernel, leaving remaining bytes charged to a
+// cgroup when memory.reclaim is written and failing with err
+func fakeKernel(remaining int64, err error) func(string, []byte, os.FileMode) error {
+	return func(name string, data []byte, perm os.FileMode) error {
+		current := filepath.Join(filepath.Dir(name), "memory.current")
+		if werr := os.WriteFile(current, []byte(strconv.FormatInt(remaining, 10)), 0644); werr != nil {
+			return werr
+		}
+		return err
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctrDir        string
+		podUID        string
+		remaining     int64
+		kernelErr     error
+		wantReclaimed int64
+		wantErr       bool
+	}{{
+		name:          "systemd driver",
+		ctrDir:        "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234_abcd.slice/cri-containerd-ctr1.scope",
+		podUID:        "1234-abcd",
+		remaining:     1024,
+		wantReclaimed: 3072,
+	}, {
+		name:          "systemd driver guaranteed pod",
+		ctrDir:        "kubepods.slice/kubepods-pod1234_abcd.slice/crio-ctr1.scope",
+		podUID:        "1234-abcd",
+		wantReclaimed: 4096,
+	}, {
+		name:          "cgroupfs driver",
+		ctrDir:        "kubepods/besteffort/pod1234-abcd/ctr1",
+		podUID:        "1234-abcd",
+		wantReclaimed: 4096,
+	}, {
+		name:          "partially reclaimed",
+		ctrDir:        "kubepods/besteffort/pod1234-abcd/ctr1",
+		podUID:        "1234-abcd",
+		remaining:     3072,
+		kernelErr:     syscall.EAGAIN,
+		wantReclaimed: 1024,
+	}, {
+		name:      "reclaim failed",
+		ctrDir:    "kubepods/besteffort/pod1234-abcd/ctr1",
+		podUID:    "1234-abcd",
+		remaining: 4096,
+		kernelErr: syscall.EINVAL,
+		wantErr:   true,
+	}, {
+		name:    "pod not found",
+		ctrDir:  "kubepods/besteffort/pod1234-abcd/ctr1",
+		podUID:  "5678",
+		wantErr: true,
+	}, {
+		name:    "container not found",
+		ctrDir:  "kubepods/besteffort/pod1234-abcd/ctr2",
+		podUID:  "1234-abcd",
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewReclaimer(fakeCgroupFS(t, c.ctrDir, 4096))
+			r.writeFile = fakeKernel(c.remaining, c.kernelErr)
+
+			reclaimed, err := r.Reclaim(context.Background(), c.podUID, []string{"ctr1"})
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if reclaimed != c.wantReclaimed {
+				t.Errorf("expected %d bytes reclaimed but got %d", c.wantReclaimed, reclaimed)
+			}
+		})
+	}
+}
+
+func TestReclaimWritesCurrentUsage(t *testing.T) {
+	root := fakeCgroupFS(t, "kubepods/pod1234/ctr1", 4096)
+	if _, err := NewReclaimer(root).Reclaim(context.Background(), "1234", []string{"ctr1"}); err != nil {
+		t.Fatalf("expected reclaim to succeed but failed: %v", err)
+	}
+
+	written, err := os.ReadFile(filepath.Join(root, "kubepods/pod1234/ctr1/memory.reclaim"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != "4096" {
+		t.Errorf("expected all memory to be reclaimed but asked for %q", written)
+	}
+}
+
+func TestReclaimCgroupV1(t *testing.T) {
+	if _, err := NewReclaimer(t.TempDir()).Reclaim(context.Background(), "1234", []string{"ctr1"}); err != ErrUnsupported {
+		t.Errorf("expected %v but got %v", ErrUnsupported, err)
+	}
+}
+
+func TestReclaimStopsWhenCancelled(t *testing.T) {
+	current := int64(3 * reclaimChunk)
+	r := NewReclaimer(fakeCgroupFS(t, "kubepods/pod1234/ctr1", current))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writes := 0
+	r.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		writes++
+		// The resume arrives while the kernel reclaims the first chunk
+		cancel()
+		return fakeKernel(current-reclaimChunk, nil)(name, data, perm)
+	}
+
+	reclaimed, err := r.Reclaim(ctx, "1234", []string{"ctr1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the reclaim to be cancelled but got %v", err)
+	}
+	if writes != 1 || reclaimed != reclaimChunk {
+		t.Errorf("expected a single chunk of %d bytes to be reclaimed but got %d bytes in %d writes", reclaimChunk, reclaimed, writes)
+	}
+}
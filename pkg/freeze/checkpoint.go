@@ -0,0 +1,172 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+const ActionCheckpoint = "checkpoint"
+
+// Checkpointer is implemented by runtimes which can checkpoint a paused
+// container to disk, releasing its memory, and restore it later
+type Checkpointer interface {
+	Checkpoint(ctx context.Context, container string) error
+	Restore(ctx context.Context, container string) error
+}
+
+// WithCheckpointAfter checkpoints pods which have been frozen for d to disk,
+// if the runtime supports it. Pods stay paused if it does not.
+func WithCheckpointAfter(d time.Duration) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.checkpointAfter = d
+	}
+}
+
+// scheduleCheckpointLocked arranges for the frozen pod to be checkpointed
+// once it has been frozen long enough. c.mu must be held.
+func (c *ContainerRuntimeImpl) scheduleCheckpointLocked(podName string, st *podState) {
+	if c.checkpointAfter <= 0 || st.checkpoint != nil || st.noCheckpoint || st.mode == ModeThrottle {
+		return
+	}
+	if _, ok := c.cri.(Checkpointer); !ok {
+		return
+	}
+	gen := c.nextGenLocked()
+	st.checkpointGen = gen
+	st.checkpoint = c.getClock().AfterFunc(c.checkpointAfter, func() { go c.fireCheckpoint(podName, gen) })
+}
+
+// cancelCheckpointLocked cancels a pending checkpoint of the pod. c.mu must be held.
+func cancelCheckpointLocked(st *podState) {
+	if st.checkpoint != nil {
+		st.checkpoint.Stop()
+		st.checkpoint = nil
+	}
+}
+
+// fireCheckpoint checkpoints the pod unless the checkpoint has been cancelled
+func (c *ContainerRuntimeImpl) fireCheckpoint(podName string, gen uint64) {
+	c.mu.Lock()
+	st := c.pods[podName]
+	if st == nil || st.checkpoint == nil || st.checkpointGen != gen {
+		c.mu.Unlock()
+		return
+	}
+	st.checkpoint = nil
+	c.mu.Unlock()
+
+	if err := c.checkpoint(context.Background(), podName); err != nil {
+		c.getLogger().Errorf("checkpointing pod %s failed: %v", podName, err)
+	}
+}
+
+// checkpoint checkpoints the paused containers of the pod. Like a freeze it
+// stops between containers if a resume for the pod arrives.
+func (c *ContainerRuntimeImpl) checkpoint(ctx context.Context, podName string) error {
+	err := c.serialize(ctx, podName, true, func(st *podState) error {
+		superseded := st.superseded
+		release, err := c.pool.acquire(ctx, false, superseded)
+		if errors.Is(err, errPoolCancelled) {
+			recordSuppressed(reasonSuperseded)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		c.mu.Lock()
+		var containerIDs []string
+		for _, ctr := range st.paused {
+			if !contains(st.checkpointed, ctr) {
+				containerIDs = append(containerIDs, ctr)
+			}
+		}
+		c.mu.Unlock()
+
+		for _, ctr := range containerIDs {
+			select {
+			case <-superseded:
+				recordSuppressed(reasonSuperseded)
+				return nil
+			default:
+			}
+			err := c.checkpointContainer(ctx, ctr)
+			if errors.Is(err, common.ErrCheckpointUnsupported) {
+				c.getLogger().Infof("runtime cannot checkpoint pod %s, leaving it paused: %v", podName, err)
+				c.mu.Lock()
+				st.noCheckpoint = true
+				c.mu.Unlock()
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			c.mu.Lock()
+			st.checkpointed = append(st.checkpointed, ctr)
+			c.saveCheckpointedLocked(podName, st)
+			c.mu.Unlock()
+		}
+		return nil
+	})
+	c.recordError(podName, ActionCheckpoint, err)
+	return err
+}
+
+// saveCheckpointedLocked keeps the checkpointed containers of the pod in the
+// state directory, so that a restarted daemon restores rather than resumes
+// them. c.mu must be held.
+func (c *ContainerRuntimeImpl) saveCheckpointedLocked(podName string, st *podState) {
+	if c.stateDir == "" {
+		return
+	}
+	path := c.checkpointedPath(podName)
+	if len(st.checkpointed) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			c.getLogger().Warnf("forgetting checkpointed containers of pod %s: %v", podName, err)
+		}
+		return
+	}
+	b, err := json.Marshal(st.checkpointed)
+	if err == nil {
+		err = os.MkdirAll(c.stateDir, 0o700)
+	}
+	// Written then renamed, so that a crash never leaves a partial file
+	if err == nil {
+		err = os.WriteFile(path+".tmp", b, 0o600)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		c.getLogger().Warnf("saving checkpointed containers of pod %s: %v", podName, err)
+	}
+}
+
+// loadCheckpointed returns the checkpointed containers of the pod kept in the
+// state directory
+func (c *ContainerRuntimeImpl) loadCheckpointed(podName string) []string {
+	if c.stateDir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(c.checkpointedPath(podName))
+	if err != nil {
+		return nil
+	}
+	var ctrs []string
+	if err := json.Unmarshal(b, &ctrs); err != nil {
+		c.getLogger().Warnf("reading checkpointed containers of pod %s: %v", podName, err)
+		return nil
+	}
+	return ctrs
+}
+
+func (c *ContainerRuntimeImpl) checkpointedPath(podName string) string {
+	return filepath.Join(c.stateDir, "checkpointed-"+filepath.Base(podName)+".json")
+}
@@ -0,0 +1,151 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// checkpointingCRI is a recordingCRI which can checkpoint containers
+type checkpointingCRI struct {
+	*recordingCRI
+	unsupported bool
+	// hang makes checkpoints hang until they are given up on
+	hang bool
+}
+
+func (c checkpointingCRI) Checkpoint(ctx context.Context, container string) error {
+	if c.unsupported {
+		return fmt.Errorf("%s not checkpointed: %w", container, common.ErrCheckpointUnsupported)
+	}
+	if c.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c.record("checkpoint")
+	return nil
+}
+
+func (c checkpointingCRI) Restore(ctx context.Context, container string) error {
+	c.record("restore")
+	return nil
+}
+
+func TestCheckpointAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		unsupported bool
+		thawAfter   time.Duration
+		wantCalls   []string
+	}{{
+		name:      "checkpointed and restored",
+		thawAfter: time.Hour,
+		wantCalls: []string{"pause", "checkpoint", "restore"},
+	}, {
+		name:      "thawed before the checkpoint",
+		thawAfter: time.Minute,
+		wantCalls: []string{"pause", "resume"},
+	}, {
+		name:        "checkpointing unsupported",
+		unsupported: true,
+		thawAfter:   time.Hour,
+		wantCalls:   []string{"pause", "resume"},
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			clk := clocktesting.NewFakeClock(time.Now())
+			fake := checkpointingCRI{recordingCRI: newRecordingCRI(), unsupported: c.unsupported}
+			impl := &ContainerRuntimeImpl{cri: fake, clock: clk, checkpointAfter: 10 * time.Minute}
+
+			if err := impl.Freeze(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected freeze to succeed but failed: %v", err)
+			}
+			clk.Step(c.thawAfter)
+			if c.thawAfter > impl.checkpointAfter && !c.unsupported {
+				fake.waitForCalls(t, 2)
+			}
+			waitForIdle(t, impl, "pod")
+
+			if err := impl.Thaw(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected thaw to succeed but failed: %v", err)
+			}
+			if calls := fake.getCalls(); !reflect.DeepEqual(calls, c.wantCalls) {
+				t.Errorf("expected calls %v but got %v", c.wantCalls, calls)
+			}
+			if len(impl.pods) != 0 {
+				t.Errorf("expected no pod state to be kept but got %d entries", len(impl.pods))
+			}
+		})
+	}
+}
+
+func TestCheckpointTimeout(t *testing.T) {
+	fake := checkpointingCRI{recordingCRI: newRecordingCRI(), hang: true}
+	impl := &ContainerRuntimeImpl{cri: fake, timeouts: Timeouts{Checkpoint: 10 * time.Millisecond}}
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := impl.checkpoint(context.Background(), "pod"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected the checkpoint to time out but got %v", err)
+	}
+
+	// The pod stays paused, and is resumed rather than restored
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if want, calls := []string{"pause", "resume"}, fake.getCalls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v but got %v", want, calls)
+	}
+}
+
+func TestCheckpointedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	fake := checkpointingCRI{recordingCRI: newRecordingCRI()}
+	impl := &ContainerRuntimeImpl{cri: fake, stateDir: dir}
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := impl.checkpoint(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected checkpoint to succeed but failed: %v", err)
+	}
+
+	// A restarted daemon knows nothing of the pod but its state directory
+	restarted := &ContainerRuntimeImpl{cri: fake, stateDir: dir}
+	if err := restarted.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if want, calls := []string{"pause", "checkpoint", "restore"}, fake.getCalls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v but got %v", want, calls)
+	}
+	if ctrs := restarted.loadCheckpointed("pod"); len(ctrs) != 0 {
+		t.Errorf("expected the restored containers to be forgotten but got %v", ctrs)
+	}
+}
+
+// waitForIdle waits until no operation is running on the pod
+func waitForIdle(t *testing.T, c *ContainerRuntimeImpl, podName string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		st := c.pods[podName]
+		idle := st == nil || st.busy == nil
+		c.mu.Unlock()
+		if idle {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pod to be idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// ContainerCache remembers the containers of pods, so that pausing and
+// resuming a pod does not list them through the runtime every time. The
+// containers of a pod change when one exits or is removed, so the cache is
+// only used while something invalidates it on such events. A nil
+// ContainerCache caches nothing.
+type ContainerCache struct {
+	mu      sync.Mutex
+	enabled bool
+	// gen changes on every invalidation, so that a listing which raced with
+	// one is not cached
+	gen    uint64
+	pods   map[string][]string
+	owners map[string]string
+}
+
+// NewContainerCache returns a cache which is disabled until Enable is called
+func NewContainerCache() *ContainerCache {
+	return &ContainerCache{
+		pods:   make(map[string][]string),
+		owners: make(map[string]string),
+	}
+}
+
+// List returns the containers of the pod from the cache, or lists them with
+// list and caches them
+func (c *ContainerCache) List(ctx context.Context, podUID string, list func(ctx context.Context, podUID string) ([]string, error)) ([]string, error) {
+	if c == nil {
+		return list(ctx, podUID)
+	}
+
+	c.mu.Lock()
+	if ids, ok := c.pods[podUID]; ok {
+		c.mu.Unlock()
+		return append([]string(nil), ids...), nil
+	}
+	enabled, gen := c.enabled, c.gen
+	c.mu.Unlock()
+
+	ids, err := list(ctx, podUID)
+	if err != nil || !enabled {
+		return ids, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enabled && c.gen == gen {
+		c.pods[podUID] = append([]string(nil), ids...)
+		for _, id := range ids {
+			c.owners[id] = podUID
+		}
+	}
+	return ids, nil
+}
+
+// Invalidate forgets the pod the container belongs to, if it is cached
+func (c *ContainerCache) Invalidate(containerID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	podUID, ok := c.owners[containerID]
+	if !ok {
+		return
+	}
+	for _, id := range c.pods[podUID] {
+		delete(c.owners, id)
+	}
+	delete(c.pods, podUID)
+}
+
+// Enable starts caching, once invalidation events are being received
+func (c *ContainerCache) Enable() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = true
+}
+
+// Disable forgets every pod and stops caching, when invalidation events may
+// be missed
+func (c *ContainerCache) Disable() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = false
+	c.gen++
+	c.pods = make(map[string][]string)
+	c.owners = make(map[string]string)
+}
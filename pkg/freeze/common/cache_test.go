@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// countingLister lists the given containers and counts the calls
+type countingLister struct {
+	ids   []string
+	calls int
+	// during is called while listing
+	during func()
+}
+
+func (l *countingLister) list(ctx context.Context, podUID string) ([]string, error) {
+	l.calls++
+	if l.during != nil {
+		l.during()
+	}
+	return l.ids, nil
+}
+
+func TestContainerCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		cache     func() *ContainerCache
+		between   func(c *ContainerCache)
+		during    func(c *ContainerCache)
+		wantCalls int
+	}{{
+		name:      "cached",
+		cache:     enabledCache,
+		wantCalls: 1,
+	}, {
+		name:      "nil cache",
+		cache:     func() *ContainerCache { return nil },
+		wantCalls: 2,
+	}, {
+		name:      "disabled",
+		cache:     NewContainerCache,
+		wantCalls: 2,
+	}, {
+		name:      "container of the pod exited",
+		cache:     enabledCache,
+		between:   func(c *ContainerCache) { c.Invalidate("ctr1") },
+		wantCalls: 2,
+	}, {
+		name:      "container of another pod exited",
+		cache:     enabledCache,
+		between:   func(c *ContainerCache) { c.Invalidate("other") },
+		wantCalls: 1,
+	}, {
+		name:      "events missed",
+		cache:     enabledCache,
+		between:   func(c *ContainerCache) { c.Disable() },
+		wantCalls: 2,
+	}, {
+		name:      "invalidated while listing",
+		cache:     enabledCache,
+		during:    func(c *ContainerCache) { c.Invalidate("ctr1") },
+		wantCalls: 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := test.cache()
+			lister := &countingLister{ids: []string{"ctr1", "ctr2"}}
+			if test.during != nil {
+				lister.during = func() {
+					lister.during = nil
+					test.during(cache)
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				ids, err := cache.List(context.Background(), "pod", lister.list)
+				if err != nil {
+					t.Fatalf("expected list to succeed but got %v", err)
+				}
+				if !reflect.DeepEqual(ids, lister.ids) {
+					t.Errorf("expected %v but got %v", lister.ids, ids)
+				}
+				if i == 0 && test.between != nil {
+					test.between(cache)
+				}
+			}
+			if lister.calls != test.wantCalls {
+				t.Errorf("expected %d listings but got %d", test.wantCalls, lister.calls)
+			}
+		})
+	}
+}
+
+func enabledCache() *ContainerCache {
+	c := NewContainerCache()
+	c.Enable()
+	return c
+}
@@ -11,9 +11,37 @@ import (
 
 var ErrNoNonQueueProxyPods = errors.New("no non queue-proxy containers found in pod")
 
+// ErrCheckpointUnsupported is returned by runtimes which cannot checkpoint containers
+var ErrCheckpointUnsupported = errors.New("checkpointing is not supported by the runtime")
+
+// ErrStatusUnsupported is returned by runtimes which cannot report the state of containers
+var ErrStatusUnsupported = errors.New("reporting the state of containers is not supported by the runtime")
+
+// ContainerState is the state of a container as far as freezing is concerned
+type ContainerState string
+
+const (
+	StateUnknown ContainerState = "unknown"
+	StateRunning ContainerState = "running"
+	StatePaused  ContainerState = "paused"
+	StateStopped ContainerState = "stopped"
+	// StatePausing is a container which is being paused, a container stays
+	// pausing if one of its tasks cannot be frozen
+	StatePausing ContainerState = "pausing"
+)
+
+// ContainerEvent is a change to a container reported by the runtime, whoever
+// made it
+type ContainerEvent struct {
+	ContainerID string
+	// State is StatePaused, StateRunning once resumed, or StateStopped once
+	// the container exited or was deleted
+	State ContainerState
+}
+
 func List(ctx context.Context, conn *grpc.ClientConn, podUID string) ([]string, error) {
 	client := cri.NewRuntimeServiceClient(conn)
-	pods, err := client.ListPodSandbox(context.Background(), &cri.ListPodSandboxRequest{
+	pods, err := client.ListPodSandbox(ctx, &cri.ListPodSandboxRequest{
 		Filter: &cri.PodSandboxFilter{
 			LabelSelector: map[string]string{
 				"io.kubernetes.pod.uid": podUID,
@@ -44,6 +72,31 @@ func List(ctx context.Context, conn *grpc.ClientConn, podUID string) ([]string,
 	return containerIDs, nil
 }
 
+// RuntimeHandler returns the RuntimeClass handler the sandbox of the pod runs
+// with, empty for the runtime's default handler
+func RuntimeHandler(ctx context.Context, conn *grpc.ClientConn, podUID string) (string, error) {
+	client := cri.NewRuntimeServiceClient(conn)
+	pods, err := client.ListPodSandbox(ctx, &cri.ListPodSandboxRequest{
+		Filter: &cri.PodSandboxFilter{
+			LabelSelector: map[string]string{
+				"io.kubernetes.pod.uid": podUID,
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("pod %s not found", podUID)
+	}
+
+	status, err := client.PodSandboxStatus(ctx, &cri.PodSandboxStatusRequest{PodSandboxId: pods.Items[0].Id})
+	if err != nil {
+		return "", err
+	}
+	return status.GetStatus().GetRuntimeHandler(), nil
+}
+
 func lookupContainerIDs(ctrs *cri.ListContainersResponse) ([]string, error) {
 	ids := make([]string, 0, len(ctrs.Containers)-1)
 	for _, c := range ctrs.Containers {
@@ -108,3 +108,28 @@ func TestListContainers(t *testing.T) {
 		}
 	}
 }
+
+func TestRuntimeHandler(t *testing.T) {
+	socketPath := test.GetRandomSocketPath()
+	criServer := test.NewCriRuntimeServer()
+	go test.RunCriServer(criServer, socketPath)
+	time.Sleep(time.Millisecond * 50)
+
+	ctx := context.Background()
+	criServer.AddPodSandboxForCRI(test.MockPod{Id: "pod1", RuntimeHandler: "kata"})
+	criServer.AddPodSandboxForCRI(test.MockPod{Id: "pod2"})
+	conn, err := test.NewCRIGrpcClient(ctx, socketPath)
+	if err != nil {
+		t.Fatalf("New grpc client error:%v", err)
+	}
+
+	if handler, err := RuntimeHandler(ctx, conn, "pod1"); err != nil || handler != "kata" {
+		t.Errorf("expected handler kata but got %q, %v", handler, err)
+	}
+	if handler, err := RuntimeHandler(ctx, conn, "pod2"); err != nil || handler != "" {
+		t.Errorf("expected the default handler but got %q, %v", handler, err)
+	}
+	if _, err := RuntimeHandler(ctx, conn, "pod3"); err == nil {
+		t.Error("expected looking up an unknown pod to fail")
+	}
+}
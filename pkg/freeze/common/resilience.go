@@ -0,0 +1,202 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/utils/clock"
+)
+
+// ErrCircuitOpen is returned without calling the runtime while it is
+// considered down
+var ErrCircuitOpen = errors.New("container runtime unavailable, failing fast")
+
+const (
+	defaultAttempts         = 3
+	defaultBackoff          = 100 * time.Millisecond
+	defaultMaxBackoff       = 2 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 10 * time.Second
+)
+
+// Resilience retries runtime calls which failed transiently with exponential
+// backoff, and fails calls fast through its breaker while the runtime is
+// down. A nil Resilience makes every call once.
+type Resilience struct {
+	// Attempts is how many times a call is made at most
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Breaker    *Breaker
+}
+
+// NewResilience returns the policy the built-in backends use
+func NewResilience() *Resilience {
+	return &Resilience{
+		Attempts:   defaultAttempts,
+		Backoff:    defaultBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		Breaker:    NewBreaker(defaultBreakerThreshold, defaultBreakerCooldown),
+	}
+}
+
+// Do calls fn until it succeeds, fails with an error transient does not
+// accept, or runs out of attempts. Calls are not retried once ctx is done.
+func (r *Resilience) Do(ctx context.Context, transient func(error) bool, fn func(ctx context.Context) error) error {
+	if r == nil {
+		return fn(ctx)
+	}
+
+	delay := r.Backoff
+	for attempt := 1; ; attempt++ {
+		if err := r.Breaker.allow(); err != nil {
+			return err
+		}
+		err := fn(ctx)
+		if ctx.Err() != nil {
+			// A call given up on by its caller says nothing about the runtime
+			r.Breaker.abandon()
+			return err
+		}
+		retry := err != nil && transient(err)
+		r.Breaker.record(retry)
+		if !retry || attempt >= r.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; r.MaxBackoff > 0 && delay > r.MaxBackoff {
+			delay = r.MaxBackoff
+		}
+	}
+}
+
+// DialOptions returns the options for connections to the runtime, which
+// reconnect with backoff and retry calls failing with IsTransientGRPC
+func (r *Resilience) DialOptions() []grpc.DialOption {
+	backoffConfig := backoff.DefaultConfig
+	backoffConfig.MaxDelay = 10 * time.Second
+	return []grpc.DialOption{
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoffConfig, MinConnectTimeout: 5 * time.Second}),
+		grpc.WithChainUnaryInterceptor(r.UnaryClientInterceptor()),
+	}
+}
+
+// UnaryClientInterceptor applies r to every call made on a connection
+func (r *Resilience) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return r.Do(ctx, IsTransientGRPC, func(ctx context.Context) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// IsTransientGRPC reports whether a gRPC call failed because the runtime was
+// unreachable or too slow to answer, rather than refusing the call
+func IsTransientGRPC(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// IsTransientNet reports whether a call failed because the runtime could not
+// be reached, e.g. while it restarts
+func IsTransientNet(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// Breaker trips after Threshold consecutive transient failures, then fails
+// calls fast for Cooldown, after which a single call probes the runtime. A
+// nil Breaker never trips.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	clock clock.PassiveClock
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns a breaker tripping after threshold consecutive transient
+// failures for cooldown
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, clock: clock.RealClock{}}
+}
+
+// Open reports whether the breaker is failing calls fast
+func (b *Breaker) Open() bool {
+	return b.allowed(false) != nil
+}
+
+func (b *Breaker) allow() error {
+	return b.allowed(true)
+}
+
+func (b *Breaker) allowed(probe bool) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.probing || b.clock.Since(b.openedAt) < b.Cooldown {
+		return ErrCircuitOpen
+	}
+	if probe {
+		b.probing = true
+	}
+	return nil
+}
+
+// record records the outcome of a call, any answer of the runtime shows it
+// is up
+func (b *Breaker) record(transient bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbing := b.probing
+	b.probing = false
+	if !transient {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if wasProbing || b.failures >= b.Threshold {
+		b.openedAt = b.clock.Now()
+	}
+}
+
+// abandon records that a call ended without an outcome, so that the next one
+// probes the runtime if this one was probing
+func (b *Breaker) abandon() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,189 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+var (
+	errUnavailable = status.Error(codes.Unavailable, "connection refused")
+	errNotFound    = status.Error(codes.NotFound, "container not found")
+)
+
+// failing returns a call failing with errs in turn and succeeding once they
+// run out, and counts the calls made
+func failing(errs ...error) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if len(errs) == 0 {
+			return nil
+		}
+		err := errs[0]
+		errs = errs[1:]
+		return err
+	}, &calls
+}
+
+func TestResilienceDo(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{{
+		name:      "success",
+		wantCalls: 1,
+	}, {
+		name:      "transient failure retried",
+		errs:      []error{errUnavailable, status.Error(codes.DeadlineExceeded, "slow")},
+		wantCalls: 3,
+	}, {
+		name:      "attempts exhausted",
+		errs:      []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable},
+		wantErr:   errUnavailable,
+		wantCalls: 3,
+	}, {
+		name:      "permanent failure not retried",
+		errs:      []error{errNotFound},
+		wantErr:   errNotFound,
+		wantCalls: 1,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			r := &Resilience{Attempts: 3, Backoff: time.Millisecond}
+			fn, calls := failing(c.errs...)
+			if err := r.Do(context.Background(), IsTransientGRPC, fn); err != c.wantErr {
+				t.Errorf("expected error %v but got %v", c.wantErr, err)
+			}
+			if *calls != c.wantCalls {
+				t.Errorf("expected %d calls but got %d", c.wantCalls, *calls)
+			}
+		})
+	}
+}
+
+func TestResilienceStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Resilience{Attempts: 3, Backoff: time.Millisecond}
+	calls := 0
+	err := r.Do(ctx, IsTransientGRPC, func(context.Context) error {
+		calls++
+		cancel()
+		return errUnavailable
+	})
+	if err != errUnavailable || calls != 1 {
+		t.Errorf("expected a single call once the context is done but got %d calls, %v", calls, err)
+	}
+
+	var nilResilience *Resilience
+	fn, nilCalls := failing(errUnavailable)
+	if err := nilResilience.Do(context.Background(), IsTransientGRPC, fn); err != errUnavailable || *nilCalls != 1 {
+		t.Errorf("expected a nil policy to call once but got %d calls, %v", *nilCalls, err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	b := &Breaker{Threshold: 2, Cooldown: time.Minute, clock: clk}
+	r := &Resilience{Attempts: 1, Breaker: b}
+	ctx := context.Background()
+
+	// A permanent failure shows the runtime is up
+	fn, _ := failing(errUnavailable, errNotFound, errUnavailable, errUnavailable)
+	for i := 0; i < 4; i++ {
+		r.Do(ctx, IsTransientGRPC, fn)
+	}
+	if !b.Open() {
+		t.Fatal("expected the breaker to trip after consecutive transient failures")
+	}
+
+	fn, calls := failing()
+	if err := r.Do(ctx, IsTransientGRPC, fn); err != ErrCircuitOpen || *calls != 0 {
+		t.Errorf("expected to fail fast but got %d calls, %v", *calls, err)
+	}
+
+	// A failed probe trips the breaker again straight away
+	clk.SetTime(clk.Now().Add(time.Minute))
+	fn, calls = failing(errUnavailable)
+	if err := r.Do(ctx, IsTransientGRPC, fn); err != errUnavailable || *calls != 1 {
+		t.Errorf("expected the runtime to be probed but got %d calls, %v", *calls, err)
+	}
+	if !b.Open() {
+		t.Fatal("expected a failed probe to trip the breaker")
+	}
+
+	clk.SetTime(clk.Now().Add(time.Minute))
+	fn, calls = failing()
+	if err := r.Do(ctx, IsTransientGRPC, fn); err != nil || *calls != 1 {
+		t.Errorf("expected the runtime to be probed but got %d calls, %v", *calls, err)
+	}
+	if b.Open() {
+		t.Error("expected a successful probe to close the breaker")
+	}
+}
+
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	b := &Breaker{Threshold: 2, Cooldown: time.Minute, clock: clk}
+	r := &Resilience{Attempts: 1, Breaker: b}
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	errCanceled := status.Error(codes.Canceled, "context canceled")
+
+	// A cancelled call does not reset the consecutive failures
+	fn, _ := failing(errUnavailable, errCanceled, errUnavailable)
+	r.Do(ctx, IsTransientGRPC, fn)
+	r.Do(cancelled, IsTransientGRPC, fn)
+	r.Do(ctx, IsTransientGRPC, fn)
+	if !b.Open() {
+		t.Fatal("expected the breaker to trip after consecutive transient failures")
+	}
+
+	// A cancelled probe does not close the breaker, and the next call probes
+	clk.SetTime(clk.Now().Add(time.Minute))
+	fn, calls := failing(errCanceled, errUnavailable)
+	if err := r.Do(cancelled, IsTransientGRPC, fn); err != errCanceled || *calls != 1 {
+		t.Errorf("expected the runtime to be probed but got %d calls, %v", *calls, err)
+	}
+	if err := r.Do(ctx, IsTransientGRPC, fn); err != errUnavailable || *calls != 2 {
+		t.Errorf("expected the runtime to be probed again but got %d calls, %v", *calls, err)
+	}
+	if !b.Open() {
+		t.Error("expected the failed probe to trip the breaker")
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	r := &Resilience{Attempts: 3, Backoff: time.Millisecond}
+	fn, calls := failing(errUnavailable)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return fn(ctx)
+	}
+
+	if err := r.UnaryClientInterceptor()(context.Background(), "/runtime.v1alpha2.RuntimeService/ListPodSandbox", nil, nil, nil, invoker); err != nil {
+		t.Errorf("expected the call to be retried but failed: %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 calls but got %d", *calls)
+	}
+}
+
+func TestIsTransientNet(t *testing.T) {
+	if !IsTransientNet(&net.OpError{Op: "dial", Net: "unix", Err: errors.New("connection refused")}) {
+		t.Error("expected a dial error to be transient")
+	}
+	if IsTransientNet(errors.New("container not running")) {
+		t.Error("expected an error of CRI-O to be permanent")
+	}
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/grpc"
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const (
+	// A throttled container gets 1% of a CPU and the lowest weight
+	throttledCPUShares = 2
+	throttledCPUQuota  = 1000
+	throttledCPUPeriod = 100000
+
+	// defaultCPUShares and defaultCPUPeriod are what the runtime uses when the
+	// container did not specify them
+	defaultCPUShares = 1024
+	defaultCPUPeriod = 100000
+	// unlimitedCPUQuota removes the quota
+	unlimitedCPUQuota = -1
+)
+
+// CPUThrottler throttles the CPU of containers through the CRI rather than
+// pausing them, and remembers their original CPU resources so they can be
+// restored. The zero value is ready to use.
+type CPUThrottler struct {
+	// StateDir, if set, is where the original resources are kept as well, so
+	// that containers throttled before the daemon restarted are restored
+	StateDir string
+
+	mu       sync.Mutex
+	original map[string]*cri.LinuxContainerResources
+}
+
+// Throttle drops the CPU quota and shares of the container to a minimum
+func (t *CPUThrottler) Throttle(ctx context.Context, conn *grpc.ClientConn, container string) error {
+	client := cri.NewRuntimeServiceClient(conn)
+
+	if _, throttled := t.lookup(container); !throttled {
+		original, err := cpuResources(ctx, client, container)
+		if err != nil {
+			return err
+		}
+		// A container at the minimum was throttled by a daemon which lost
+		// track of it, its original resources are not these
+		if !isThrottled(original) {
+			if err := t.record(container, original); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := client.UpdateContainerResources(ctx, &cri.UpdateContainerResourcesRequest{
+		ContainerId: container,
+		Linux: &cri.LinuxContainerResources{
+			CpuShares: throttledCPUShares,
+			CpuQuota:  throttledCPUQuota,
+			CpuPeriod: throttledCPUPeriod,
+		},
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Unthrottle restores the CPU resources the container had before Throttle.
+// If they are unknown the container is given the resources of a container
+// without CPU requests and limits, rather than staying throttled.
+func (t *CPUThrottler) Unthrottle(ctx context.Context, conn *grpc.ClientConn, container string) error {
+	original, ok := t.lookup(container)
+	if !ok {
+		original = &cri.LinuxContainerResources{
+			CpuShares: defaultCPUShares,
+			CpuQuota:  unlimitedCPUQuota,
+			CpuPeriod: defaultCPUPeriod,
+		}
+	}
+
+	client := cri.NewRuntimeServiceClient(conn)
+	if _, err := client.UpdateContainerResources(ctx, &cri.UpdateContainerResourcesRequest{
+		ContainerId: container,
+		Linux:       original,
+	}); err != nil {
+		return err
+	}
+
+	t.forget(container)
+	return nil
+}
+
+// lookup returns the original resources of the container, from memory or
+// from the state directory
+func (t *CPUThrottler) lookup(container string) (*cri.LinuxContainerResources, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if original, ok := t.original[container]; ok {
+		return original, true
+	}
+	if t.StateDir == "" {
+		return nil, false
+	}
+	b, err := os.ReadFile(t.statePath(container))
+	if err != nil {
+		return nil, false
+	}
+	var original cri.LinuxContainerResources
+	if err := json.Unmarshal(b, &original); err != nil || isThrottled(&original) {
+		return nil, false
+	}
+	if t.original == nil {
+		t.original = make(map[string]*cri.LinuxContainerResources)
+	}
+	t.original[container] = &original
+	return &original, true
+}
+
+// record remembers the original resources of the container, in memory and in
+// the state directory
+func (t *CPUThrottler) record(container string, original *cri.LinuxContainerResources) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.StateDir != "" {
+		b, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(t.StateDir, 0o700); err != nil {
+			return fmt.Errorf("saving original resources: %v", err)
+		}
+		// Written then renamed, so that a crash never leaves a partial file
+		tmp := t.statePath(container) + ".tmp"
+		if err := os.WriteFile(tmp, b, 0o600); err != nil {
+			return fmt.Errorf("saving original resources: %v", err)
+		}
+		if err := os.Rename(tmp, t.statePath(container)); err != nil {
+			return fmt.Errorf("saving original resources: %v", err)
+		}
+	}
+	if t.original == nil {
+		t.original = make(map[string]*cri.LinuxContainerResources)
+	}
+	t.original[container] = original
+	return nil
+}
+
+func (t *CPUThrottler) forget(container string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.original, container)
+	if t.StateDir != "" {
+		os.Remove(t.statePath(container))
+	}
+}
+
+func (t *CPUThrottler) statePath(container string) string {
+	return filepath.Join(t.StateDir, "throttled-"+filepath.Base(container)+".json")
+}
+
+// isThrottled reports whether the resources are those of a throttled container
+func isThrottled(res *cri.LinuxContainerResources) bool {
+	return res.CpuShares == throttledCPUShares && res.CpuQuota == throttledCPUQuota && res.CpuPeriod == throttledCPUPeriod
+}
+
+// runtimeInfo is the part of the verbose container status of containerd and
+// CRI-O holding the OCI spec of the container
+type runtimeInfo struct {
+	RuntimeSpec struct {
+		Linux struct {
+			Resources struct {
+				CPU struct {
+					Shares *uint64 `json:"shares"`
+					Quota  *int64  `json:"quota"`
+					Period *uint64 `json:"period"`
+				} `json:"cpu"`
+			} `json:"resources"`
+		} `json:"linux"`
+	} `json:"runtimeSpec"`
+}
+
+// cpuResources returns the CPU resources of the container, filling in what
+// it did not specify so that they can be applied as they are
+func cpuResources(ctx context.Context, client cri.RuntimeServiceClient, container string) (*cri.LinuxContainerResources, error) {
+	status, err := client.ContainerStatus(ctx, &cri.ContainerStatusRequest{ContainerId: container, Verbose: true})
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := status.GetInfo()["info"]
+	if !ok {
+		return nil, fmt.Errorf("runtime did not report the resources of the container")
+	}
+	var info runtimeInfo
+	if err := json.Unmarshal([]byte(raw), &info); err != nil {
+		return nil, fmt.Errorf("decoding container info: %v", err)
+	}
+
+	cpu := info.RuntimeSpec.Linux.Resources.CPU
+	res := &cri.LinuxContainerResources{
+		CpuShares: defaultCPUShares,
+		CpuQuota:  unlimitedCPUQuota,
+		CpuPeriod: defaultCPUPeriod,
+	}
+	if cpu.Shares != nil {
+		res.CpuShares = int64(*cpu.Shares)
+	}
+	if cpu.Quota != nil && *cpu.Quota > 0 {
+		res.CpuQuota = *cpu.Quota
+	}
+	if cpu.Period != nil {
+		res.CpuPeriod = int64(*cpu.Period)
+	}
+	return res, nil
+}
+
+// PodAnnotations returns the annotations of the pod, which the kubelet
+// passes on to its sandbox
+func PodAnnotations(ctx context.Context, conn *grpc.ClientConn, podUID string) (map[string]string, error) {
+	client := cri.NewRuntimeServiceClient(conn)
+	pods, err := client.ListPodSandbox(ctx, &cri.ListPodSandboxRequest{
+		Filter: &cri.PodSandboxFilter{
+			LabelSelector: map[string]string{
+				"io.kubernetes.pod.uid": podUID,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("pod %s not found", podUID)
+	}
+	return pods.Items[0].Annotations, nil
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"knative.dev/container-freezer/pkg/freeze/test"
+)
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		name         string
+		info         string
+		wantErr      bool
+		wantRestored *cri.LinuxContainerResources
+	}{{
+		name:         "limited container",
+		info:         `{"runtimeSpec":{"linux":{"resources":{"cpu":{"shares":512,"quota":50000,"period":100000}}}}}`,
+		wantRestored: &cri.LinuxContainerResources{CpuShares: 512, CpuQuota: 50000, CpuPeriod: 100000},
+	}, {
+		name:         "unlimited container",
+		info:         `{"runtimeSpec":{"linux":{"resources":{"cpu":{"shares":2}}}}}`,
+		wantRestored: &cri.LinuxContainerResources{CpuShares: 2, CpuQuota: -1, CpuPeriod: 100000},
+	}, {
+		name:    "resources not reported",
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			socketPath := test.GetRandomSocketPath()
+			criServer := test.NewCriRuntimeServer()
+			go test.RunCriServer(criServer, socketPath)
+			time.Sleep(time.Millisecond * 50)
+
+			ctx := context.Background()
+			criServer.AddPodSandboxForCRI(test.MockPod{
+				Id:   "pod1",
+				Ctrs: []test.MockCtr{{Id: "ctr1", Name: "ctr1", Info: c.info}},
+			})
+			conn, err := test.NewCRIGrpcClient(ctx, socketPath)
+			if err != nil {
+				t.Fatalf("New grpc client error:%v", err)
+			}
+
+			var throttler CPUThrottler
+			err = throttler.Throttle(ctx, conn, "ctr1")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr {
+				if len(criServer.Updates) != 0 {
+					t.Errorf("expected no resources to be updated but got %v", criServer.Updates)
+				}
+				return
+			}
+
+			if err := throttler.Unthrottle(ctx, conn, "ctr1"); err != nil {
+				t.Fatalf("expected unthrottle to succeed but failed: %v", err)
+			}
+			if len(criServer.Updates) != 2 {
+				t.Fatalf("expected 2 resource updates but got %d", len(criServer.Updates))
+			}
+			throttled := criServer.Updates[0].Linux
+			if throttled.CpuShares != throttledCPUShares || throttled.CpuQuota != throttledCPUQuota {
+				t.Errorf("expected the container to be throttled but got %v", throttled)
+			}
+			restored := criServer.Updates[1].Linux
+			if restored.CpuShares != c.wantRestored.CpuShares || restored.CpuQuota != c.wantRestored.CpuQuota || restored.CpuPeriod != c.wantRestored.CpuPeriod {
+				t.Errorf("expected resources %v to be restored but got %v", c.wantRestored, restored)
+			}
+
+			// Unknown resources are not left throttled
+			if err := throttler.Unthrottle(ctx, conn, "ctr1"); err != nil {
+				t.Fatalf("expected unthrottle to succeed but failed: %v", err)
+			}
+			if got := criServer.Updates[2].Linux; got.CpuShares != defaultCPUShares || got.CpuQuota != unlimitedCPUQuota {
+				t.Errorf("expected unthrottled resources but got %v", got)
+			}
+		})
+	}
+}
+
+func TestThrottleStateDir(t *testing.T) {
+	tests := []struct {
+		name         string
+		info         string
+		wantRestored *cri.LinuxContainerResources
+	}{{
+		name:         "limited container",
+		info:         `{"runtimeSpec":{"linux":{"resources":{"cpu":{"shares":512,"quota":50000,"period":100000}}}}}`,
+		wantRestored: &cri.LinuxContainerResources{CpuShares: 512, CpuQuota: 50000, CpuPeriod: 100000},
+	}, {
+		name:         "throttled by a daemon which lost its state",
+		info:         `{"runtimeSpec":{"linux":{"resources":{"cpu":{"shares":2,"quota":1000,"period":100000}}}}}`,
+		wantRestored: &cri.LinuxContainerResources{CpuShares: defaultCPUShares, CpuQuota: unlimitedCPUQuota, CpuPeriod: defaultCPUPeriod},
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			socketPath := test.GetRandomSocketPath()
+			criServer := test.NewCriRuntimeServer()
+			go test.RunCriServer(criServer, socketPath)
+			time.Sleep(time.Millisecond * 50)
+
+			ctx := context.Background()
+			criServer.AddPodSandboxForCRI(test.MockPod{
+				Id:   "pod1",
+				Ctrs: []test.MockCtr{{Id: "ctr1", Name: "ctr1", Info: c.info}},
+			})
+			conn, err := test.NewCRIGrpcClient(ctx, socketPath)
+			if err != nil {
+				t.Fatalf("New grpc client error:%v", err)
+			}
+
+			dir := t.TempDir()
+			before := &CPUThrottler{StateDir: dir}
+			if err := before.Throttle(ctx, conn, "ctr1"); err != nil {
+				t.Fatalf("expected throttle to succeed but failed: %v", err)
+			}
+
+			// The daemon restarted
+			after := &CPUThrottler{StateDir: dir}
+			if err := after.Unthrottle(ctx, conn, "ctr1"); err != nil {
+				t.Fatalf("expected unthrottle to succeed but failed: %v", err)
+			}
+			restored := criServer.Updates[len(criServer.Updates)-1].Linux
+			if restored.CpuShares != c.wantRestored.CpuShares || restored.CpuQuota != c.wantRestored.CpuQuota || restored.CpuPeriod != c.wantRestored.CpuPeriod {
+				t.Errorf("expected resources %v to be restored but got %v", c.wantRestored, restored)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Errorf("expected the state of the unthrottled container to be removed but got %v", files)
+			}
+		})
+	}
+}
+
+func TestPodAnnotations(t *testing.T) {
+	socketPath := test.GetRandomSocketPath()
+	criServer := test.NewCriRuntimeServer()
+	go test.RunCriServer(criServer, socketPath)
+	time.Sleep(time.Millisecond * 50)
+
+	ctx := context.Background()
+	want := map[string]string{"container-freezer.knative.dev/mode": "throttle"}
+	criServer.AddPodSandboxForCRI(test.MockPod{Id: "pod1", Annotations: want})
+	conn, err := test.NewCRIGrpcClient(ctx, socketPath)
+	if err != nil {
+		t.Fatalf("New grpc client error:%v", err)
+	}
+
+	got, err := PodAnnotations(ctx, conn, "pod1")
+	if err != nil {
+		t.Fatalf("expected looking up annotations to succeed but failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected annotations %v but got %v", want, got)
+	}
+	if _, err := PodAnnotations(ctx, conn, "pod2"); err == nil {
+		t.Error("expected looking up an unknown pod to fail")
+	}
+}
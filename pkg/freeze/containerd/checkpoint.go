@@ -0,0 +1,164 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/leases"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/runtime/v2/runc/options"
+	"github.com/containerd/typeurl"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// checkpoint is what Restore needs to release a checkpoint. It is kept in the
+// state directory as well, so that the daemon finds it after restarting.
+type checkpoint struct {
+	Descriptor *types.Descriptor `json:"descriptor"`
+	// Lease keeps the checkpoint in the content store until it is restored
+	Lease string `json:"lease"`
+}
+
+// Checkpoint dumps a paused container to disk with CRIU. The task is left
+// paused rather than exited, since containerd would report the exit to the
+// kubelet through the CRI, which would restart the container. Restore
+// resumes it and releases the checkpoint.
+func (c *ContainerdCRI) Checkpoint(ctx context.Context, container string) error {
+	ctx = namespaces.WithNamespace(ctx, "k8s.io")
+
+	opts, err := typeurl.MarshalAny(&options.CheckpointOptions{OpenTcp: true, FileLocks: true})
+	if err != nil {
+		return err
+	}
+
+	lease, err := c.ctrd.LeasesService().Create(ctx, leases.WithRandomID())
+	if err != nil {
+		return fmt.Errorf("%s not checkpointed: %w", container, err)
+	}
+	resp, err := c.ctrd.TaskService().Checkpoint(leases.WithLease(ctx, lease.ID), &tasks.CheckpointTaskRequest{
+		ContainerID: container,
+		Options:     opts,
+	})
+	if err != nil {
+		c.ctrd.LeasesService().Delete(ctx, lease)
+		if status.Code(err) == codes.Unimplemented || errdefs.IsNotImplemented(errdefs.FromGRPC(err)) {
+			return fmt.Errorf("%s not checkpointed: %w", container, common.ErrCheckpointUnsupported)
+		}
+		return fmt.Errorf("%s not checkpointed: %w", container, err)
+	}
+
+	cp := &checkpoint{Lease: lease.ID}
+	for _, d := range resp.Descriptors {
+		if d.MediaType == images.MediaTypeContainerd1Checkpoint {
+			cp.Descriptor = d
+		}
+	}
+	if cp.Descriptor == nil {
+		c.ctrd.LeasesService().Delete(ctx, lease)
+		return fmt.Errorf("%s not checkpointed: no checkpoint image returned", container)
+	}
+
+	if err := c.saveCheckpoint(container, cp); err != nil {
+		c.ctrd.LeasesService().Delete(ctx, lease)
+		return fmt.Errorf("%s not checkpointed: %w", container, err)
+	}
+	return nil
+}
+
+// Restore resumes a container checkpointed by Checkpoint and releases its
+// checkpoint
+func (c *ContainerdCRI) Restore(ctx context.Context, container string) error {
+	ctx = namespaces.WithNamespace(ctx, "k8s.io")
+
+	cp := c.lookupCheckpoint(container)
+	if cp == nil {
+		return errors.New("no checkpoint")
+	}
+	if _, err := c.ctrd.TaskService().Resume(ctx, &tasks.ResumeTaskRequest{ContainerID: container}); err != nil {
+		c.invalidateIfGone(container, err)
+		return err
+	}
+
+	c.ctrd.LeasesService().Delete(ctx, leases.Lease{ID: cp.Lease})
+	c.forgetCheckpoint(container)
+	return nil
+}
+
+// saveCheckpoint remembers the checkpoint of the container, in memory and in
+// the state directory
+func (c *ContainerdCRI) saveCheckpoint(container string, cp *checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stateDir != "" {
+		b, err := json.Marshal(cp)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.stateDir, 0o700); err != nil {
+			return fmt.Errorf("saving checkpoint: %v", err)
+		}
+		// Written then renamed, so that a crash never leaves a partial file
+		tmp := c.checkpointPath(container) + ".tmp"
+		if err := os.WriteFile(tmp, b, 0o600); err != nil {
+			return fmt.Errorf("saving checkpoint: %v", err)
+		}
+		if err := os.Rename(tmp, c.checkpointPath(container)); err != nil {
+			return fmt.Errorf("saving checkpoint: %v", err)
+		}
+	}
+	if c.checkpoints == nil {
+		c.checkpoints = make(map[string]*checkpoint)
+	}
+	c.checkpoints[container] = cp
+	return nil
+}
+
+// lookupCheckpoint returns the checkpoint of the container, from memory or
+// from the state directory
+func (c *ContainerdCRI) lookupCheckpoint(container string) *checkpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cp, ok := c.checkpoints[container]; ok {
+		return cp
+	}
+	if c.stateDir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(c.checkpointPath(container))
+	if err != nil {
+		return nil
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil || cp.Lease == "" {
+		return nil
+	}
+	if c.checkpoints == nil {
+		c.checkpoints = make(map[string]*checkpoint)
+	}
+	c.checkpoints[container] = &cp
+	return &cp
+}
+
+func (c *ContainerdCRI) forgetCheckpoint(container string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checkpoints, container)
+	if c.stateDir != "" {
+		os.Remove(c.checkpointPath(container))
+	}
+}
+
+func (c *ContainerdCRI) checkpointPath(container string) string {
+	return filepath.Join(c.stateDir, "checkpoint-"+filepath.Base(container)+".json")
+}
@@ -0,0 +1,83 @@
+package containerd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+	"knative.dev/container-freezer/pkg/freeze/test"
+)
+
+func TestCheckpointAndRestore(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       string
+		unsupported bool
+		wantErr     error
+		wantState   string
+	}{{
+		name:      "paused container is checkpointed",
+		state:     "paused",
+		wantState: "paused",
+	}, {
+		name:        "checkpointing unsupported",
+		state:       "paused",
+		unsupported: true,
+		wantErr:     common.ErrCheckpointUnsupported,
+		wantState:   "paused",
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider, _, ctrdServer, err := runServerAndCreateProvider(ctx)
+			if err != nil {
+				t.Fatalf("init error:%v", err)
+			}
+			ctrdServer.CheckpointUnsupported = c.unsupported
+			provider.SetStateDir(t.TempDir())
+			ctrdServer.AddCtrForCtrd(test.MockCtr{Id: "ctr1", Name: "ctr1", State: c.state})
+
+			err = provider.Checkpoint(ctx, "ctr1")
+			if c.wantErr != nil {
+				if !errors.Is(err, c.wantErr) {
+					t.Errorf("expected error %v but got %v", c.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected checkpoint to succeed but failed: %v", err)
+			}
+			if state := ctrdServer.CtrState("ctr1"); state != c.wantState {
+				t.Errorf("expected state %s after checkpoint but got %s", c.wantState, state)
+			}
+			if c.wantErr != nil {
+				if len(ctrdServer.Leases) != 0 {
+					t.Errorf("expected the lease to be released but got %v", ctrdServer.Leases)
+				}
+				if err := provider.Restore(ctx, "ctr1"); err == nil {
+					t.Error("expected restoring a container without a checkpoint to fail")
+				}
+				return
+			}
+
+			if len(ctrdServer.Leases) != 1 {
+				t.Errorf("expected the checkpoint to be held by a lease but got %v", ctrdServer.Leases)
+			}
+
+			// A restarted daemon finds the checkpoint in the state directory
+			provider.checkpoints = nil
+			if err := provider.Restore(ctx, "ctr1"); err != nil {
+				t.Fatalf("expected restore to succeed but failed: %v", err)
+			}
+			if state := ctrdServer.CtrState("ctr1"); state != "running" {
+				t.Errorf("expected the container to be running after restore but got %s", state)
+			}
+			if len(ctrdServer.Leases) != 0 {
+				t.Errorf("expected the lease to be released but got %v", ctrdServer.Leases)
+			}
+			if cp := provider.lookupCheckpoint("ctr1"); cp != nil {
+				t.Errorf("expected the checkpoint to be forgotten but got %+v", cp)
+			}
+		})
+	}
+}
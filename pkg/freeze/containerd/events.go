@@ -0,0 +1,117 @@
+package containerd
+
+import (
+	"context"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/events"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/typeurl"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// resubscribeDelay is how long to wait before subscribing to containerd
+// events again after the subscription failed
+const resubscribeDelay = time.Second
+
+// eventFilters select the events which change the containers of a pod or
+// their state
+var eventFilters = []string{
+	`namespace=="k8s.io",topic=="/tasks/exit"`,
+	`namespace=="k8s.io",topic=="/tasks/delete"`,
+	`namespace=="k8s.io",topic=="/tasks/paused"`,
+	`namespace=="k8s.io",topic=="/tasks/resumed"`,
+	`namespace=="k8s.io",topic=="/containers/delete"`,
+}
+
+// WatchEvents calls fn for every container paused, resumed, exited or deleted
+// in the k8s.io namespace, by the freezer or anyone else. Events are missed
+// while containerd cannot be reached.
+func (c *ContainerdCRI) WatchEvents(fn func(common.ContainerEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvent = fn
+}
+
+// watchEvents follows the containerd events until ctx is done, invalidating
+// the container cache and passing them on to the WatchEvents function. The
+// cache is only used while subscribed, since events are missed otherwise.
+// Subscribing returns before containerd has registered the subscription, so
+// caching only starts with the first event received, the freezer's own
+// pauses cause one soon enough.
+func (c *ContainerdCRI) watchEvents(ctx context.Context) {
+	ctx = namespaces.WithNamespace(ctx, "k8s.io")
+	for {
+		envelopes, errs := c.ctrd.Subscribe(ctx, eventFilters...)
+		subscribed := false
+	receive:
+		for {
+			select {
+			case envelope := <-envelopes:
+				if !subscribed {
+					c.cache.Enable()
+					subscribed = true
+				}
+				c.handleEvent(envelope)
+			case <-errs:
+				break receive
+			case <-ctx.Done():
+				c.cache.Disable()
+				return
+			}
+		}
+		c.cache.Disable()
+
+		select {
+		case <-time.After(resubscribeDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleEvent forgets the pod of a container which exited or was deleted,
+// and reports the change of state
+func (c *ContainerdCRI) handleEvent(envelope *events.Envelope) {
+	if envelope == nil || envelope.Event == nil {
+		return
+	}
+	event, err := typeurl.UnmarshalAny(envelope.Event)
+	if err != nil {
+		return
+	}
+
+	var changed common.ContainerEvent
+	switch e := event.(type) {
+	case *apievents.TaskExit:
+		c.cache.Invalidate(e.ContainerID)
+		// Exec processes exit without the container stopping
+		if e.ID != "" && e.ID != e.ContainerID {
+			return
+		}
+		changed = common.ContainerEvent{ContainerID: e.ContainerID, State: common.StateStopped}
+	case *apievents.TaskDelete:
+		if e.ID != "" && e.ID != e.ContainerID {
+			return
+		}
+		changed = common.ContainerEvent{ContainerID: e.ContainerID, State: common.StateStopped}
+	case *apievents.ContainerDelete:
+		c.cache.Invalidate(e.ID)
+		changed = common.ContainerEvent{ContainerID: e.ID, State: common.StateStopped}
+	case *apievents.TaskPaused:
+		changed = common.ContainerEvent{ContainerID: e.ContainerID, State: common.StatePaused}
+	case *apievents.TaskResumed:
+		changed = common.ContainerEvent{ContainerID: e.ContainerID, State: common.StateRunning}
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	onEvent := c.onEvent
+	c.mu.Unlock()
+	if onEvent != nil {
+		onEvent(changed)
+	}
+}
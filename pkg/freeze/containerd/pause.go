@@ -2,12 +2,14 @@ package containerd
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/containerd/containerd"
 	"github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/namespaces"
 	"google.golang.org/grpc"
 
@@ -18,12 +20,18 @@ const defaultContainerdAddress = "/var/run/containerd/containerd.sock"
 
 // NewContainerdProvider returns a CRI based on Containerd
 func NewContainerdProvider() (*ContainerdCRI, error) {
+	return NewContainerdProviderAt(defaultContainerdAddress)
+}
+
+// NewContainerdProviderAt returns a CRI based on Containerd listening on the unix socket at address
+func NewContainerdProviderAt(address string) (*ContainerdCRI, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, defaultContainerdAddress, grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024*1024*16)), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+	opts := append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024 * 1024 * 16)), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 		return (&net.Dialer{}).DialContext(ctx, "unix", addr)
-	}))
+	})}, common.NewResilience().DialOptions()...)
+	conn, err := grpc.DialContext(ctx, address, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -33,25 +41,37 @@ func NewContainerdProvider() (*ContainerdCRI, error) {
 		return nil, err
 	}
 
-	return &ContainerdCRI{conn: conn, ctrd: client}, nil
+	c := &ContainerdCRI{conn: conn, ctrd: client, cache: common.NewContainerCache()}
+	go c.watchEvents(context.Background())
+	return c, nil
 }
 
 type ContainerdCRI struct {
 	conn *grpc.ClientConn
 	ctrd *containerd.Client
+	// cache holds the containers of pods while watchEvents keeps it fresh
+	cache *common.ContainerCache
+
+	mu          sync.Mutex
+	stateDir    string
+	checkpoints map[string]*checkpoint
+	onEvent     func(common.ContainerEvent)
+	throttler   common.CPUThrottler
 }
 
 // List returns a list of all non queue-proxy container IDs in a given pod
 func (c *ContainerdCRI) List(ctx context.Context, podUID string) ([]string, error) {
-	containerIDs, err := common.List(ctx, c.conn, podUID)
-	return containerIDs, err
+	return c.cache.List(ctx, podUID, func(ctx context.Context, podUID string) ([]string, error) {
+		return common.List(ctx, c.conn, podUID)
+	})
 }
 
 // Pause performs a pause action on a specific container
 func (c *ContainerdCRI) Pause(ctx context.Context, container string) error {
 	ctx = namespaces.WithNamespace(ctx, "k8s.io")
 	if _, err := c.ctrd.TaskService().Pause(ctx, &tasks.PauseTaskRequest{ContainerID: container}); err != nil {
-		return fmt.Errorf("%s not paused: %v", container, err)
+		c.invalidateIfGone(container, err)
+		return err
 	}
 	return nil
 }
@@ -60,7 +80,72 @@ func (c *ContainerdCRI) Pause(ctx context.Context, container string) error {
 func (c *ContainerdCRI) Resume(ctx context.Context, container string) error {
 	ctx = namespaces.WithNamespace(ctx, "k8s.io")
 	if _, err := c.ctrd.TaskService().Resume(ctx, &tasks.ResumeTaskRequest{ContainerID: container}); err != nil {
-		return fmt.Errorf("%s not resumed: %v", container, err)
+		c.invalidateIfGone(container, err)
+		return err
 	}
 	return nil
 }
+
+// invalidateIfGone forgets the pod of a container containerd no longer knows,
+// in case the event saying so was missed
+func (c *ContainerdCRI) invalidateIfGone(container string, err error) {
+	if errdefs.IsNotFound(errdefs.FromGRPC(err)) {
+		c.cache.Invalidate(container)
+	}
+}
+
+// taskStates maps the status of containerd tasks to container states
+var taskStates = map[task.Status]common.ContainerState{
+	task.StatusCreated: common.StateStopped,
+	task.StatusRunning: common.StateRunning,
+	task.StatusStopped: common.StateStopped,
+	task.StatusPaused:  common.StatePaused,
+	task.StatusPausing: common.StatePausing,
+}
+
+// Status returns the state of the task of a specific container, a container
+// without a task is stopped
+func (c *ContainerdCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	ctx = namespaces.WithNamespace(ctx, "k8s.io")
+	resp, err := c.ctrd.TaskService().Get(ctx, &tasks.GetRequest{ContainerID: container})
+	if err != nil {
+		if errdefs.IsNotFound(errdefs.FromGRPC(err)) {
+			return common.StateStopped, nil
+		}
+		return common.StateUnknown, err
+	}
+	if state, ok := taskStates[resp.Process.Status]; ok {
+		return state, nil
+	}
+	return common.StateUnknown, nil
+}
+
+// SetStateDir keeps the original CPU resources of throttled containers and
+// the checkpoints of containers in dir, so that they are restored after the
+// daemon restarts
+func (c *ContainerdCRI) SetStateDir(dir string) {
+	c.mu.Lock()
+	c.stateDir = dir
+	c.mu.Unlock()
+	c.throttler.StateDir = dir
+}
+
+// Throttle drops the CPU of a specific container to a minimum instead of pausing it
+func (c *ContainerdCRI) Throttle(ctx context.Context, container string) error {
+	return c.throttler.Throttle(ctx, c.conn, container)
+}
+
+// Unthrottle restores the CPU of a specific container throttled by Throttle
+func (c *ContainerdCRI) Unthrottle(ctx context.Context, container string) error {
+	return c.throttler.Unthrottle(ctx, c.conn, container)
+}
+
+// PodAnnotations returns the annotations of a given pod
+func (c *ContainerdCRI) PodAnnotations(ctx context.Context, podUID string) (map[string]string, error) {
+	return common.PodAnnotations(ctx, c.conn, podUID)
+}
+
+// RuntimeHandler returns the RuntimeClass handler of a given pod
+func (c *ContainerdCRI) RuntimeHandler(ctx context.Context, podUID string) (string, error) {
+	return common.RuntimeHandler(ctx, c.conn, podUID)
+}
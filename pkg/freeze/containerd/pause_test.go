@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
 
+	"knative.dev/container-freezer/pkg/freeze/common"
 	"knative.dev/container-freezer/pkg/freeze/test"
 )
 
@@ -76,6 +78,129 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListCachedUntilEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		topic string
+		event interface{}
+	}{{
+		name:  "task exit",
+		topic: "/tasks/exit",
+		event: &apievents.TaskExit{ContainerID: "ctr1"},
+	}, {
+		name:  "container delete",
+		topic: "/containers/delete",
+		event: &apievents.ContainerDelete{ID: "ctr1"},
+	}}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			provider, criServer, ctrdServer, err := runServerAndCreateProvider(ctx)
+			if err != nil {
+				t.Fatalf("init error:%v", err)
+			}
+			provider.cache = common.NewContainerCache()
+			received := make(chan common.ContainerEvent, 10)
+			provider.WatchEvents(func(ev common.ContainerEvent) { received <- ev })
+			go provider.watchEvents(ctx)
+			waitFor(t, func() bool { return ctrdServer.Subscribers() == 1 })
+
+			// Nothing is cached until an event confirms the subscription
+			criServer.AddPodSandboxForCRI(test.MockPod{Id: "pod1", Ctrs: []test.MockCtr{{Id: "ctr0", Name: "ctr1"}}})
+			if ids, err := provider.List(ctx, "pod1"); err != nil || !reflect.DeepEqual(ids, []string{"ctr0"}) {
+				t.Fatalf("expected ctr0 but got %v, %v", ids, err)
+			}
+			criServer.Pod[0].Ctrs[0].Id = "ctr1"
+			if err := ctrdServer.PublishEvent("/tasks/paused", &apievents.TaskPaused{ContainerID: "other"}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the event")
+			}
+
+			if ids, err := provider.List(ctx, "pod1"); err != nil || !reflect.DeepEqual(ids, []string{"ctr1"}) {
+				t.Fatalf("expected ctr1 but got %v, %v", ids, err)
+			}
+
+			// The container restarts, which is only noticed once its exit is
+			criServer.Pod[0].Ctrs[0].Id = "ctr2"
+			if ids, err := provider.List(ctx, "pod1"); err != nil || !reflect.DeepEqual(ids, []string{"ctr1"}) {
+				t.Fatalf("expected ctr1 to be cached but got %v, %v", ids, err)
+			}
+
+			if err := ctrdServer.PublishEvent(v.topic, v.event); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, func() bool {
+				ids, err := provider.List(ctx, "pod1")
+				return err == nil && reflect.DeepEqual(ids, []string{"ctr2"})
+			})
+		})
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider, _, ctrdServer, err := runServerAndCreateProvider(ctx)
+	if err != nil {
+		t.Fatalf("init error:%v", err)
+	}
+	received := make(chan common.ContainerEvent, 10)
+	provider.WatchEvents(func(ev common.ContainerEvent) { received <- ev })
+	go provider.watchEvents(ctx)
+	waitFor(t, func() bool { return ctrdServer.Subscribers() == 1 })
+
+	for _, published := range []struct {
+		topic string
+		event interface{}
+	}{
+		{"/tasks/paused", &apievents.TaskPaused{ContainerID: "ctr1"}},
+		{"/tasks/resumed", &apievents.TaskResumed{ContainerID: "ctr1"}},
+		// An exec process exiting leaves the container running
+		{"/tasks/exit", &apievents.TaskExit{ContainerID: "ctr1", ID: "exec1"}},
+		{"/tasks/exit", &apievents.TaskExit{ContainerID: "ctr1", ID: "ctr1"}},
+		{"/tasks/delete", &apievents.TaskDelete{ContainerID: "ctr2", ID: "ctr2"}},
+	} {
+		if err := ctrdServer.PublishEvent(published.topic, published.event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []common.ContainerEvent{
+		{ContainerID: "ctr1", State: common.StatePaused},
+		{ContainerID: "ctr1", State: common.StateRunning},
+		{ContainerID: "ctr1", State: common.StateStopped},
+		{ContainerID: "ctr2", State: common.StateStopped},
+	}
+	for _, w := range want {
+		select {
+		case got := <-received:
+			if got != w {
+				t.Errorf("expected event %+v but got %+v", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected event %+v but got none", w)
+		}
+	}
+}
+
+// waitFor waits for cond to hold, failing the test if it does not soon
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
 func TestPause(t *testing.T) {
 	tests := []struct {
 		ctrsAdd     test.MockCtr
@@ -157,3 +282,51 @@ func TestResume(t *testing.T) {
 		}
 	}
 }
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctrsAdd   test.MockCtr
+		reqCtrId  string
+		wantState common.ContainerState
+	}{{
+		name:      "running",
+		ctrsAdd:   test.MockCtr{Id: "ctr1", Name: "ctr1", State: "running"},
+		reqCtrId:  "ctr1",
+		wantState: common.StateRunning,
+	}, {
+		name:      "paused",
+		ctrsAdd:   test.MockCtr{Id: "ctr1", Name: "ctr1", State: "paused"},
+		reqCtrId:  "ctr1",
+		wantState: common.StatePaused,
+	}, {
+		name:      "stuck pausing",
+		ctrsAdd:   test.MockCtr{Id: "ctr1", Name: "ctr1", State: "pausing"},
+		reqCtrId:  "ctr1",
+		wantState: common.StatePausing,
+	}, {
+		name:      "task gone",
+		ctrsAdd:   test.MockCtr{Id: "ctr1", Name: "ctr1", State: "running"},
+		reqCtrId:  "ctr2",
+		wantState: common.StateStopped,
+	}}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider, _, ctrdServer, err := runServerAndCreateProvider(ctx)
+			if err != nil {
+				t.Fatalf("init error:%v", err)
+			}
+
+			ctrdServer.AddCtrForCtrd(v.ctrsAdd)
+			state, err := provider.Status(ctx, v.reqCtrId)
+			if err != nil {
+				t.Fatalf("expected status to succeed but got %v", err)
+			}
+			if state != v.wantState {
+				t.Errorf("expected state %s but got %s", v.wantState, state)
+			}
+		})
+	}
+}
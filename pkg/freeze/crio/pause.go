@@ -2,13 +2,16 @@ package crio
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
+	"errors"
+	"io"
 	"net"
 	"net/http"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 
 	"knative.dev/container-freezer/pkg/freeze/common"
 )
@@ -17,33 +20,46 @@ const defaultCrioAddress = "/var/run/crio/crio.sock"
 
 // NewCrioProvider returns a CRI based on crio
 func NewCrioProvider() (*CrioCRI, error) {
+	return NewCrioProviderAt(defaultCrioAddress)
+}
+
+// NewCrioProviderAt returns a CRI based on crio listening on the unix socket at address
+func NewCrioProviderAt(address string) (*CrioCRI, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, defaultCrioAddress, grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024*1024*16)), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+	resilience := common.NewResilience()
+	opts := append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024 * 1024 * 16)), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 		return (&net.Dialer{}).DialContext(ctx, "unix", addr)
-	}))
+	})}, resilience.DialOptions()...)
+	conn, err := grpc.DialContext(ctx, address, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	client := &http.Client{
 		Transport: &http.Transport{
-			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-				return net.Dial("unix", defaultCrioAddress)
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", address)
 			},
 		},
 	}
 
-	return &CrioCRI{conn: conn, crioClient: client}, nil
+	return &CrioCRI{conn: conn, crioClient: client, resilience: resilience}, nil
 }
 
 type CrioCRI struct {
 	conn       *grpc.ClientConn
 	crioClient *http.Client
+	throttler  common.CPUThrottler
+	// resilience applies to the HTTP calls to CRI-O, the gRPC connection
+	// applies it itself
+	resilience *common.Resilience
 }
 
-// List returns a list of all non queue-proxy container IDs in a given pod
+// List returns a list of all non queue-proxy container IDs in a given pod. The
+// containers are not cached, since the CRI version in use has no container
+// events to tell when they change.
 func (c *CrioCRI) List(ctx context.Context, podUID string) ([]string, error) {
 	containerIDs, err := common.List(ctx, c.conn, podUID)
 	return containerIDs, err
@@ -51,36 +67,82 @@ func (c *CrioCRI) List(ctx context.Context, podUID string) ([]string, error) {
 
 // Pause performs a pause action on a specific container
 func (c *CrioCRI) Pause(ctx context.Context, container string) error {
-	resp, err := c.crioClient.Get("http://localhost/pause/" + container)
-	if err != nil {
-		return fmt.Errorf("%s not paused: %v", container, err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		errInfo, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("%s not paused:%v", container, err)
-		}
-		return fmt.Errorf("%s not paused:%v", container, string(errInfo))
-	}
-
-	return nil
+	return c.call(ctx, "pause", container)
 }
 
 // Resume performs a resume action on a specific container
 func (c *CrioCRI) Resume(ctx context.Context, container string) error {
-	resp, err := c.crioClient.Get("http://localhost/unpause/" + container)
+	return c.call(ctx, "unpause", container)
+}
+
+// Status reports the state of a specific container. The CRI has no paused
+// state: CRI-O reports paused containers as unknown, which is taken to mean
+// paused since the containers of pods are otherwise created, running or exited.
+func (c *CrioCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	resp, err := cri.NewRuntimeServiceClient(c.conn).ContainerStatus(ctx, &cri.ContainerStatusRequest{ContainerId: container})
+	if status.Code(err) == codes.NotFound {
+		return common.StateStopped, nil
+	}
 	if err != nil {
-		return fmt.Errorf("%s not paused: %v", container, err)
+		return common.StateUnknown, err
 	}
+	switch resp.GetStatus().GetState() {
+	case cri.ContainerState_CONTAINER_RUNNING:
+		return common.StateRunning, nil
+	case cri.ContainerState_CONTAINER_UNKNOWN:
+		return common.StatePaused, nil
+	default:
+		return common.StateStopped, nil
+	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		errInfo, err := ioutil.ReadAll(resp.Body)
+// call calls an endpoint of the CRI-O HTTP API for the container, retrying
+// while CRI-O cannot be reached
+func (c *CrioCRI) call(ctx context.Context, endpoint, container string) error {
+	return c.resilience.Do(ctx, common.IsTransientNet, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/"+endpoint+"/"+container, nil)
 		if err != nil {
-			return fmt.Errorf("%s not paused:%v", container, err)
+			return err
 		}
-		return fmt.Errorf("%s not paused:%v", container, string(errInfo))
-	}
+		resp, err := c.crioClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		errInfo, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return errors.New(string(errInfo))
+		}
+		return nil
+	})
+}
+
+// SetStateDir keeps the original CPU resources of throttled containers in dir,
+// so that they are restored after the daemon restarts
+func (c *CrioCRI) SetStateDir(dir string) {
+	c.throttler.StateDir = dir
+}
+
+// Throttle drops the CPU of a specific container to a minimum instead of pausing it
+func (c *CrioCRI) Throttle(ctx context.Context, container string) error {
+	return c.throttler.Throttle(ctx, c.conn, container)
+}
+
+// Unthrottle restores the CPU of a specific container throttled by Throttle
+func (c *CrioCRI) Unthrottle(ctx context.Context, container string) error {
+	return c.throttler.Unthrottle(ctx, c.conn, container)
+}
+
+// PodAnnotations returns the annotations of a given pod
+func (c *CrioCRI) PodAnnotations(ctx context.Context, podUID string) (map[string]string, error) {
+	return common.PodAnnotations(ctx, c.conn, podUID)
+}
 
-	return nil
+// RuntimeHandler returns the RuntimeClass handler of a given pod
+func (c *CrioCRI) RuntimeHandler(ctx context.Context, podUID string) (string, error) {
+	return common.RuntimeHandler(ctx, c.conn, podUID)
 }
@@ -2,10 +2,12 @@ package crio
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
 
+	"knative.dev/container-freezer/pkg/freeze/common"
 	"knative.dev/container-freezer/pkg/freeze/test"
 )
 
@@ -147,3 +149,82 @@ func TestResume(t *testing.T) {
 		}
 	}
 }
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		reqCtrId  string
+		wantState common.ContainerState
+	}{{
+		name:      "running",
+		state:     "running",
+		reqCtrId:  "ctr1",
+		wantState: common.StateRunning,
+	}, {
+		name:      "paused",
+		state:     "paused",
+		reqCtrId:  "ctr1",
+		wantState: common.StatePaused,
+	}, {
+		name:      "exited",
+		state:     "exited",
+		reqCtrId:  "ctr1",
+		wantState: common.StateStopped,
+	}, {
+		name:      "removed",
+		state:     "running",
+		reqCtrId:  "ctr2",
+		wantState: common.StateStopped,
+	}}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			ctx := context.Background()
+			provider, criServer, _, err := runServerAndCreateProvider(ctx)
+			if err != nil {
+				t.Fatalf("init error:%v", err)
+			}
+			criServer.AddPodSandboxForCRI(test.MockPod{Id: "pod1", Ctrs: []test.MockCtr{{Id: "ctr1", Name: "ctr1", State: v.state}}})
+
+			state, err := provider.Status(ctx, v.reqCtrId)
+			if err != nil {
+				t.Fatalf("expected status to succeed but got %v", err)
+			}
+			if state != v.wantState {
+				t.Errorf("expected state %s but got %s", v.wantState, state)
+			}
+		})
+	}
+}
+
+func TestPauseUnreachable(t *testing.T) {
+	provider := &CrioCRI{
+		crioClient: test.NewCrioHttpClient(test.GetRandomSocketPath()),
+		resilience: &common.Resilience{Attempts: 2, Backoff: time.Millisecond, Breaker: common.NewBreaker(2, time.Minute)},
+	}
+
+	if err := provider.Pause(context.Background(), "ctr1"); err == nil || errors.Is(err, common.ErrCircuitOpen) {
+		t.Errorf("expected pause to fail reaching CRI-O but got %v", err)
+	}
+	if err := provider.Pause(context.Background(), "ctr1"); !errors.Is(err, common.ErrCircuitOpen) {
+		t.Errorf("expected pause to fail fast but got %v", err)
+	}
+}
+
+func TestPauseHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	provider, _, crioServer, err := runServerAndCreateProvider(ctx)
+	if err != nil {
+		t.Fatalf("init error:%v", err)
+	}
+	crioServer.AddCrioForCtrd(test.MockCtr{Id: "ctr1", Name: "ctr1", State: "running"})
+
+	cancel()
+	if err := provider.Pause(ctx, "ctr1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected pause to be cancelled but got %v", err)
+	}
+	if err := provider.Pause(context.Background(), "ctr1"); err != nil {
+		t.Errorf("expected ctr1 to be untouched and pause to succeed but got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrTimeout is returned when a runtime call did not finish within its
+// timeout, even after the freezer rolled back or retried
+var ErrTimeout = errors.New("runtime call timed out")
+
+// resumeAttempts is how many times resuming a container is attempted when
+// the runtime does not answer in time
+const resumeAttempts = 3
+
+// Timeouts bound the runtime calls made for each container, zero means no
+// bound. Pause also bounds throttling, and Resume unthrottling. Checkpointing
+// a container writes its memory to disk, so it is bounded by Checkpoint
+// instead, and restoring it by Restore.
+type Timeouts struct {
+	List       time.Duration
+	Pause      time.Duration
+	Resume     time.Duration
+	Checkpoint time.Duration
+	Restore    time.Duration
+}
+
+// WithTimeouts bounds runtime calls. A pause which times out rolls the pod
+// back by resuming its containers, a resume which times out is retried.
+func WithTimeouts(t Timeouts) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.timeouts = t
+	}
+}
+
+// call calls fn with ctx bounded by d, and reports whether fn ran out of its
+// own time rather than ctx being done
+func call(ctx context.Context, d time.Duration, fn func(ctx context.Context) error) (expired bool, err error) {
+	if d <= 0 {
+		return false, fn(ctx)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	err = fn(callCtx)
+	return err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded), err
+}
+
+// list lists the containers of the pod
+func (c *ContainerRuntimeImpl) list(ctx context.Context, podName string) (ids []string, err error) {
+	_, err = call(ctx, c.timeouts.List, func(ctx context.Context) error {
+		ids, err = c.cri.List(ctx, podName)
+		return err
+	})
+	return ids, err
+}
+
+// listMode looks up the mode of the pod, which is bounded like listing
+func (c *ContainerRuntimeImpl) listMode(ctx context.Context, podName string) (mode string, err error) {
+	_, err = call(ctx, c.timeouts.List, func(ctx context.Context) error {
+		mode, err = c.podMode(ctx, podName)
+		return err
+	})
+	return mode, err
+}
+
+// stop pauses or throttles a container
+func (c *ContainerRuntimeImpl) stop(ctx context.Context, mode, ctr string) (expired bool, err error) {
+	return call(ctx, c.timeouts.Pause, func(ctx context.Context) error {
+		if mode == ModeThrottle {
+			if err := c.cri.(Throttler).Throttle(ctx, ctr); err != nil {
+				return fmt.Errorf("%s not throttled: %w", ctr, err)
+			}
+		} else if err := c.cri.Pause(ctx, ctr); err != nil {
+			return fmt.Errorf("%s not paused: %w", ctr, err)
+		}
+		return nil
+	})
+}
+
+// start resumes, unthrottles or restores a container, trying resumes and
+// unthrottles again while the runtime does not answer in time
+func (c *ContainerRuntimeImpl) start(ctx context.Context, mode, ctr string, restore bool) error {
+	if restore {
+		return c.restore(ctx, ctr)
+	}
+	for attempt := 1; ; attempt++ {
+		expired, err := call(ctx, c.timeouts.Resume, func(ctx context.Context) error {
+			if mode == ModeThrottle {
+				if err := c.cri.(Throttler).Unthrottle(ctx, ctr); err != nil {
+					return fmt.Errorf("%s not unthrottled: %w", ctr, err)
+				}
+			} else if err := c.cri.Resume(ctx, ctr); err != nil {
+				return fmt.Errorf("%s not resumed: %w", ctr, err)
+			}
+			return nil
+		})
+		if !expired {
+			return err
+		}
+		if attempt >= resumeAttempts {
+			return fmt.Errorf("%w: %s not resumed within %v after %d attempts", ErrTimeout, ctr, c.timeouts.Resume, attempt)
+		}
+		c.getLogger().Warnf("%s not resumed within %v, retrying", ctr, c.timeouts.Resume)
+	}
+}
+
+// checkpointContainer checkpoints a paused container. One which timed out
+// stays paused, and is checkpointed again with the pod.
+func (c *ContainerRuntimeImpl) checkpointContainer(ctx context.Context, ctr string) error {
+	expired, err := call(ctx, c.timeouts.Checkpoint, func(ctx context.Context) error {
+		return c.cri.(Checkpointer).Checkpoint(ctx, ctr)
+	})
+	if expired {
+		return fmt.Errorf("%w: %s not checkpointed within %v", ErrTimeout, ctr, c.timeouts.Checkpoint)
+	}
+	return err
+}
+
+// restore restores a checkpointed container. A restore which timed out may
+// have gone part of the way, so it is not retried.
+func (c *ContainerRuntimeImpl) restore(ctx context.Context, ctr string) error {
+	expired, err := call(ctx, c.timeouts.Restore, func(ctx context.Context) error {
+		return c.cri.(Checkpointer).Restore(ctx, ctr)
+	})
+	if expired {
+		return fmt.Errorf("%w: %s not restored within %v", ErrTimeout, ctr, c.timeouts.Restore)
+	}
+	if err != nil {
+		return fmt.Errorf("%s not restored: %w", ctr, err)
+	}
+	return nil
+}
+
+// rollback resumes the containers of a pod whose freeze failed on ctr, so the
+// pod is not left partly frozen. ctr may or may not have been stopped, so
+// failing to start it again is only logged. The error lists the containers
+// which could not be resumed.
+func (c *ContainerRuntimeImpl) rollback(podName string, st *podState, mode, ctr string) error {
+	c.mu.Lock()
+	paused := append([]string(nil), st.paused...)
+	c.mu.Unlock()
+
+	// The caller may have given up, the rollback goes ahead regardless.
+	ctx := context.Background()
+	if err := c.start(ctx, mode, ctr, false); err != nil {
+		c.getLogger().Warnf("rolling back pod %s, %v", podName, err)
+	}
+
+	var failed []string
+	for _, p := range paused {
+		if err := c.start(ctx, mode, p, false); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		c.mu.Lock()
+		st.paused = remove(st.paused, p)
+		c.mu.Unlock()
+	}
+	c.mu.Lock()
+	if len(st.paused) == 0 {
+		st.frozenAt = time.Time{}
+		st.mode = ""
+	}
+	c.mu.Unlock()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to resume: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// timedOut rolls back a pod whose freeze timed out on ctr
+func (c *ContainerRuntimeImpl) timedOut(podName string, st *podState, mode, ctr string) error {
+	err := fmt.Errorf("%w: %s not stopped within %v, pod %s rolled back", ErrTimeout, ctr, c.timeouts.Pause, podName)
+	if rbErr := c.rollback(podName, st, mode, ctr); rbErr != nil {
+		err = fmt.Errorf("%w, but %v", err, rbErr)
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// hangingCRI records its calls for a pod with two containers, and hangs until
+// the call is cancelled on pausing slow and on the first hangResumes resumes
+type hangingCRI struct {
+	mu          sync.Mutex
+	calls       []string
+	hangResumes int
+}
+
+func (h *hangingCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	return []string{"ctr", "slow"}, nil
+}
+
+func (h *hangingCRI) Pause(ctx context.Context, container string) error {
+	h.record("pause " + container)
+	if container == "slow" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (h *hangingCRI) Resume(ctx context.Context, container string) error {
+	h.record("resume " + container)
+	h.mu.Lock()
+	hang := h.hangResumes > 0
+	h.hangResumes--
+	h.mu.Unlock()
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (h *hangingCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	return common.StateUnknown, common.ErrStatusUnsupported
+}
+
+func (h *hangingCRI) record(call string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func TestFreezeTimeoutRollsBack(t *testing.T) {
+	fake := &hangingCRI{}
+	c := &ContainerRuntimeImpl{cri: fake, timeouts: Timeouts{Pause: 10 * time.Millisecond, Resume: time.Second}}
+
+	err := c.Freeze(context.Background(), "pod")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected freeze to time out but got %v", err)
+	}
+
+	want := []string{"pause ctr", "pause slow", "resume slow", "resume ctr"}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("expected calls %v but got %v", want, fake.calls)
+	}
+	if frozen := c.Frozen(); len(frozen) != 0 {
+		t.Errorf("expected the pod to be rolled back but got %+v", frozen)
+	}
+	if errs := c.RecentErrors(); len(errs) != 1 || errs[0].Action != ActionFreeze {
+		t.Errorf("expected the failed freeze to be recorded but got %+v", errs)
+	}
+}
+
+func TestThawTimeoutRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		hangResumes int
+		wantErr     bool
+		wantResumes int
+	}{{
+		name:        "answers in time",
+		wantResumes: 2,
+	}, {
+		name:        "retried",
+		hangResumes: resumeAttempts - 1,
+		wantResumes: resumeAttempts + 1,
+	}, {
+		name:        "attempts exhausted",
+		hangResumes: resumeAttempts,
+		wantErr:     true,
+		wantResumes: resumeAttempts,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &hangingCRI{}
+			impl := &ContainerRuntimeImpl{cri: fake, timeouts: Timeouts{Resume: 10 * time.Millisecond}}
+			impl.mu.Lock()
+			st := impl.podStateLocked("pod")
+			st.tracked = true
+			st.paused = []string{"ctr", "slow"}
+			st.mode = ModeFreeze
+			impl.mu.Unlock()
+			fake.hangResumes = c.hangResumes
+
+			err := impl.Thaw(context.Background(), "pod")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr && !errors.Is(err, ErrTimeout) {
+				t.Errorf("expected a timeout but got %v", err)
+			}
+			if len(fake.calls) != c.wantResumes {
+				t.Errorf("expected %d resumes but got %v", c.wantResumes, fake.calls)
+			}
+		})
+	}
+}
+
+func TestCallerCancelIsNotRetried(t *testing.T) {
+	fake := &hangingCRI{hangResumes: resumeAttempts}
+	impl := &ContainerRuntimeImpl{cri: fake, timeouts: Timeouts{Resume: time.Minute}}
+	impl.mu.Lock()
+	st := impl.podStateLocked("pod")
+	st.tracked = true
+	st.paused = []string{"ctr"}
+	impl.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := impl.Thaw(ctx, "pod"); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected the thaw to give up with the caller but got %v", err)
+	}
+	if len(fake.calls) != 1 {
+		t.Errorf("expected a single resume but got %v", fake.calls)
+	}
+}
+
+// restoringCRI is a hangingCRI whose restores take delay
+type restoringCRI struct {
+	*hangingCRI
+	delay time.Duration
+}
+
+func (r restoringCRI) Checkpoint(ctx context.Context, container string) error {
+	r.record("checkpoint " + container)
+	return nil
+}
+
+func (r restoringCRI) Restore(ctx context.Context, container string) error {
+	r.record("restore " + container)
+	select {
+	case <-time.After(r.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRestoreTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		restore time.Duration
+		wantErr bool
+	}{{
+		name:    "restores longer than resumes may take",
+		restore: time.Minute,
+	}, {
+		name:    "timed out, not retried",
+		restore: 10 * time.Millisecond,
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			fake := restoringCRI{hangingCRI: &hangingCRI{}, delay: 50 * time.Millisecond}
+			impl := &ContainerRuntimeImpl{cri: fake, timeouts: Timeouts{Resume: 10 * time.Millisecond, Restore: c.restore}}
+			impl.mu.Lock()
+			st := impl.podStateLocked("pod")
+			st.tracked = true
+			st.paused = []string{"ctr"}
+			st.checkpointed = []string{"ctr"}
+			st.mode = ModeFreeze
+			impl.mu.Unlock()
+
+			err := impl.Thaw(context.Background(), "pod")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr && !errors.Is(err, ErrTimeout) {
+				t.Errorf("expected a timeout but got %v", err)
+			}
+			if want := []string{"restore ctr"}; !reflect.DeepEqual(fake.calls, want) {
+				t.Errorf("expected calls %v but got %v", want, fake.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package freeze
+
+import (
+	"context"
+	"time"
+)
+
+// schedulePause delays the pause of the pod if a pause delay or minimum
+// running time applies. It returns false if the pod should be paused now. gen
+// is the request queued by QueueFreeze, or zero, and is used up unless false
+// is returned.
+func (c *ContainerRuntimeImpl) schedulePause(podName string, gen uint64) bool {
+	if c.pauseDelay <= 0 && c.minRunDuration <= 0 {
+		return false
+	}
+
+	clk := c.getClock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := c.pods[podName]
+	if gen != 0 {
+		// A queued request keeps the state of its pod
+		if st.latest != gen {
+			st.waiters--
+			c.forgetLocked(podName, st)
+			recordSuppressed(reasonSuperseded)
+			return true
+		}
+	}
+	if st != nil && st.pause != nil {
+		if gen != 0 {
+			st.waiters--
+		}
+		recordSuppressed(reasonPauseCoalesced)
+		return true
+	}
+	// A pod which is already paused has no running time left to protect, and
+	// a delayed pause would make a resume cancel it rather than resume the pod
+	if st != nil && len(st.paused) > 0 {
+		return false
+	}
+
+	delay, deferred := c.pauseDelay, false
+	if st != nil && c.minRunDuration > 0 && !st.thawedAt.IsZero() {
+		if remaining := st.thawedAt.Add(c.minRunDuration).Sub(clk.Now()); remaining > delay {
+			delay, deferred = remaining, true
+		}
+	}
+	if delay <= 0 {
+		return false
+	}
+
+	st = c.podStateLocked(podName)
+	if gen != 0 {
+		st.waiters--
+	}
+	pauseGen := c.nextGenLocked()
+	st.pauseGen = pauseGen
+	// The pause runs on its own goroutine so that it never runs while the
+	// clock is held, which fake clocks do when calling the function.
+	st.pause = clk.AfterFunc(delay, func() { go c.firePause(podName, pauseGen) })
+	if deferred {
+		recordSuppressed(reasonPauseDeferred)
+	}
+	return true
+}
+
+// firePause performs a delayed pause unless it has been cancelled
+func (c *ContainerRuntimeImpl) firePause(podName string, gen uint64) {
+	c.mu.Lock()
+	st := c.pods[podName]
+	if st == nil || st.pause == nil || st.pauseGen != gen {
+		c.mu.Unlock()
+		return
+	}
+	st.pause = nil
+	c.forgetLocked(podName, st)
+	c.mu.Unlock()
+
+	if err := c.freeze(context.Background(), podName); err != nil {
+		c.getLogger().Errorf("delayed freezing of pod %s failed: %v", podName, err)
+	}
+}
+
+// cancelPause cancels a pending delayed pause of the pod. It returns false if
+// there was none or containers of the pod are paused already, meaning the pod
+// needs to be resumed.
+func (c *ContainerRuntimeImpl) cancelPause(podName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := c.pods[podName]
+	if st == nil || st.pause == nil {
+		return false
+	}
+	st.pause.Stop()
+	st.pause = nil
+	// The resume is the latest request, queued pauses give way to it
+	st.latest = c.nextGenLocked()
+	c.forgetLocked(podName, st)
+	recordSuppressed(reasonPauseCancelled)
+	return len(st.paused) == 0
+}
+
+// thawed records that the pod was thawed, and forgets pods which no longer
+// need to be remembered.
+func (c *ContainerRuntimeImpl) thawed(podName string) {
+	if c.minRunDuration <= 0 {
+		return
+	}
+
+	now := c.getClock().Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, st := range c.pods {
+		if !st.thawedAt.IsZero() && now.Sub(st.thawedAt) > c.minRunDuration {
+			st.thawedAt = time.Time{}
+			c.forgetLocked(name, st)
+		}
+	}
+	c.podStateLocked(podName).thawedAt = now
+}
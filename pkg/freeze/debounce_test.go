@@ -0,0 +1,265 @@
+package freeze
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// recordingCRI records the runtime calls made for a pod with a single container
+type recordingCRI struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func newRecordingCRI() *recordingCRI {
+	return &recordingCRI{}
+}
+
+func (r *recordingCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	return []string{"ctr"}, nil
+}
+
+func (r *recordingCRI) Pause(ctx context.Context, container string) error {
+	r.record("pause")
+	return nil
+}
+
+func (r *recordingCRI) Resume(ctx context.Context, container string) error {
+	r.record("resume")
+	return nil
+}
+
+func (r *recordingCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	return common.StateUnknown, common.ErrStatusUnsupported
+}
+
+func (r *recordingCRI) record(call string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+func (r *recordingCRI) getCalls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// waitForCalls waits for n runtime calls to have been made, including those made in the background
+func (r *recordingCRI) waitForCalls(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.getCalls()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d runtime calls, got %v", n, r.getCalls())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func suppressedCount(t *testing.T, reason string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(suppressedTransitionsM.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == reasonKey && tag.Value == reason {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestPauseDelayCancelledByResume(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	var changed []string
+	c := &ContainerRuntimeImpl{cri: fake, clock: clk, pauseDelay: time.Second}
+	WithChangeFunc(func(podUID string) { changed = append(changed, podUID) })(c)
+	before := suppressedCount(t, reasonPauseCancelled)
+
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	clk.Step(500 * time.Millisecond)
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	clk.Step(time.Second)
+
+	if calls := fake.getCalls(); len(calls) != 0 {
+		t.Errorf("expected no runtime calls but got %v", calls)
+	}
+	if got := suppressedCount(t, reasonPauseCancelled) - before; got != 1 {
+		t.Errorf("expected 1 cancelled pause to be recorded but got %d", got)
+	}
+	if !reflect.DeepEqual(changed, []string{"pod"}) {
+		t.Errorf("expected the cancelled pause to be reported as a change but got %v", changed)
+	}
+	if len(c.pods) != 0 {
+		t.Errorf("expected no pod state to be kept but got %d entries", len(c.pods))
+	}
+}
+
+func TestPauseDelayElapses(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	c := &ContainerRuntimeImpl{cri: fake, clock: clk, pauseDelay: time.Second}
+	before := suppressedCount(t, reasonPauseCoalesced)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Freeze(context.Background(), "pod"); err != nil {
+			t.Fatalf("expected freeze to succeed but failed: %v", err)
+		}
+	}
+	if calls := fake.getCalls(); len(calls) != 0 {
+		t.Errorf("expected no runtime calls before the delay but got %v", calls)
+	}
+
+	clk.Step(time.Second)
+	fake.waitForCalls(t, 1)
+	if calls := fake.getCalls(); len(calls) != 1 || calls[0] != "pause" {
+		t.Errorf("expected a single pause after the delay but got %v", calls)
+	}
+	if got := suppressedCount(t, reasonPauseCoalesced) - before; got != 1 {
+		t.Errorf("expected 1 coalesced pause to be recorded but got %d", got)
+	}
+
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if calls := fake.getCalls(); len(calls) != 2 || calls[1] != "resume" {
+		t.Errorf("expected the pod to be resumed but got %v", calls)
+	}
+}
+
+func TestPauseDelayFrozenPodResumed(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	c := &ContainerRuntimeImpl{cri: fake, clock: clk, pauseDelay: time.Second}
+
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	clk.Step(time.Second)
+	fake.waitForCalls(t, 1)
+
+	// A duplicate pause of the frozen pod must not turn the resume into the
+	// cancellation of a delayed pause
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if got, want := fake.getCalls(), []string{"pause", "resume"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+	if frozen := c.Frozen(); len(frozen) != 0 {
+		t.Errorf("expected the pod to be thawed but got %+v", frozen)
+	}
+}
+
+func TestMinRunDuration(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	c := &ContainerRuntimeImpl{cri: fake, clock: clk, minRunDuration: 10 * time.Second}
+	before := suppressedCount(t, reasonPauseDeferred)
+
+	// A pod which was never thawed by the freezer is paused straight away
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if calls := fake.getCalls(); len(calls) != 2 {
+		t.Fatalf("expected a pause and a resume but got %v", calls)
+	}
+
+	clk.Step(4 * time.Second)
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if calls := fake.getCalls(); len(calls) != 2 {
+		t.Errorf("expected the pause to be deferred but got %v", calls)
+	}
+	if got := suppressedCount(t, reasonPauseDeferred) - before; got != 1 {
+		t.Errorf("expected 1 deferred pause to be recorded but got %d", got)
+	}
+
+	clk.Step(6 * time.Second)
+	fake.waitForCalls(t, 3)
+	if calls := fake.getCalls(); len(calls) != 3 || calls[2] != "pause" {
+		t.Errorf("expected the pod to be paused after its minimum running time but got %v", calls)
+	}
+}
+
+func TestQueueFreeze(t *testing.T) {
+	tests := []struct {
+		name       string
+		pauseDelay time.Duration
+		thaw       bool
+		wantCalls  []string
+	}{{
+		name:      "frozen",
+		wantCalls: []string{"pause"},
+	}, {
+		name:       "frozen after the delay",
+		pauseDelay: time.Second,
+		wantCalls:  []string{"pause"},
+	}, {
+		name: "resumed before the freeze ran",
+		thaw: true,
+		// The freezer does not know the pod, so it resumes all of its containers
+		wantCalls: []string{"resume"},
+	}, {
+		name:       "resumed before the delayed freeze ran",
+		pauseDelay: time.Second,
+		thaw:       true,
+		wantCalls:  []string{"resume"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clk := clocktesting.NewFakeClock(time.Now())
+			fake := newRecordingCRI()
+			c := &ContainerRuntimeImpl{cri: fake, clock: clk, pauseDelay: test.pauseDelay}
+
+			freeze := c.QueueFreeze("pod")
+			if test.thaw {
+				if err := c.Thaw(context.Background(), "pod"); err != nil {
+					t.Fatalf("expected thaw to succeed but failed: %v", err)
+				}
+			}
+			if err := freeze(context.Background()); err != nil {
+				t.Fatalf("expected freeze to succeed but failed: %v", err)
+			}
+			clk.Step(time.Minute)
+			fake.waitForCalls(t, len(test.wantCalls))
+
+			if got := fake.getCalls(); !reflect.DeepEqual(got, test.wantCalls) {
+				t.Errorf("expected calls %v but got %v", test.wantCalls, got)
+			}
+			if !test.thaw {
+				return
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if len(c.pods) != 0 {
+				t.Errorf("expected no pod state to be kept but got %d entries", len(c.pods))
+			}
+		})
+	}
+}
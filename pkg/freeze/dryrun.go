@@ -0,0 +1,120 @@
+package freeze
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/utils/clock"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// WithDryRun looks pods and their containers up as usual but only logs and
+// records metrics instead of pausing and resuming them. Checkpointing and
+// memory reclaim are disabled.
+func WithDryRun() Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.dryRun = true
+	}
+}
+
+// dryRunCRI lists containers through the runtime and pretends to do the rest
+type dryRunCRI struct {
+	cri    CRI
+	logger *zap.SugaredLogger
+	clock  clock.PassiveClock
+
+	mu       sync.Mutex
+	pausedAt map[string]time.Time
+}
+
+func newDryRunCRI(cri CRI, logger *zap.SugaredLogger, clk clock.PassiveClock) *dryRunCRI {
+	return &dryRunCRI{cri: cri, logger: logger, clock: clk, pausedAt: make(map[string]time.Time)}
+}
+
+func (d *dryRunCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	return d.cri.List(ctx, podUID)
+}
+
+func (d *dryRunCRI) forgetPod(podUID string) {
+	if f, ok := d.cri.(podForgetter); ok {
+		f.forgetPod(podUID)
+	}
+}
+
+// PodAnnotations looks the annotations up through the runtime, so that the
+// mode of pods is chosen as it would be without dry-run
+func (d *dryRunCRI) PodAnnotations(ctx context.Context, podUID string) (map[string]string, error) {
+	if a, ok := d.cri.(PodAnnotator); ok {
+		return a.PodAnnotations(ctx, podUID)
+	}
+	return nil, nil
+}
+
+func (d *dryRunCRI) Pause(ctx context.Context, container string) error {
+	d.stop(ModeFreeze, container)
+	return nil
+}
+
+func (d *dryRunCRI) Resume(ctx context.Context, container string) error {
+	d.start(ModeFreeze, container)
+	return nil
+}
+
+// Status reports the containers which would have been stopped as paused, so
+// that they do not show up as mismatches
+func (d *dryRunCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	d.mu.Lock()
+	_, stopped := d.pausedAt[container]
+	d.mu.Unlock()
+	if stopped {
+		return common.StatePaused, nil
+	}
+	return d.cri.Status(ctx, container)
+}
+
+// Throttle and Unthrottle are only implemented if the runtime can throttle,
+// so that throttling fails in dry-run just as it would otherwise.
+func (d *dryRunCRI) Throttle(ctx context.Context, container string) error {
+	if _, ok := d.cri.(Throttler); !ok {
+		return errNoThrottle
+	}
+	d.stop(ModeThrottle, container)
+	return nil
+}
+
+func (d *dryRunCRI) Unthrottle(ctx context.Context, container string) error {
+	if _, ok := d.cri.(Throttler); !ok {
+		return errNoThrottle
+	}
+	d.start(ModeThrottle, container)
+	return nil
+}
+
+func (d *dryRunCRI) stop(mode, container string) {
+	d.mu.Lock()
+	d.pausedAt[container] = d.clock.Now()
+	d.mu.Unlock()
+	d.logger.Infof("dry-run: would %s container %s", mode, container)
+	recordDryRun(mode, dryRunStop)
+}
+
+// start reports the container as resumed, unless it was never stopped, in
+// which case nothing would have been sent to the runtime either
+func (d *dryRunCRI) start(mode, container string) {
+	d.mu.Lock()
+	pausedAt, ok := d.pausedAt[container]
+	delete(d.pausedAt, container)
+	d.mu.Unlock()
+	if !ok {
+		d.logger.Debugf("dry-run: container %s was not stopped, nothing to resume", container)
+		return
+	}
+
+	frozenFor := d.clock.Since(pausedAt)
+	d.logger.Infof("dry-run: would resume container %s, which would have been stopped by %s for %v", container, mode, frozenFor)
+	recordDryRun(mode, dryRunStart)
+	recordDryRunStopped(mode, frozenFor)
+}
@@ -0,0 +1,127 @@
+package freeze
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	clocktesting "k8s.io/utils/clock/testing"
+	ltesting "knative.dev/pkg/logging/testing"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		cri     CRI
+		mode    string
+		wantErr bool
+	}{{
+		name: "freeze",
+		cri:  newRecordingCRI(),
+	}, {
+		name: "throttle",
+		cri:  throttlingCRI{recordingCRI: newRecordingCRI()},
+		mode: ModeThrottle,
+	}, {
+		name:    "throttle unsupported",
+		cri:     newRecordingCRI(),
+		mode:    ModeThrottle,
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			start := time.Now()
+			clk := clocktesting.NewFakeClock(start)
+			dryRun := newDryRunCRI(c.cri, ltesting.TestLogger(t), clk)
+			impl := &ContainerRuntimeImpl{cri: dryRun, clock: clk, mode: c.mode}
+
+			err := impl.Freeze(context.Background(), "pod")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr {
+				return
+			}
+
+			wantMode := c.mode
+			if wantMode == "" {
+				wantMode = ModeFreeze
+			}
+			want := []FrozenPod{{PodUID: "pod", Containers: []string{"ctr"}, FrozenAt: start, Mode: wantMode}}
+			if got := impl.Frozen(); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected frozen pods %+v but got %+v", want, got)
+			}
+			if at, ok := dryRun.pausedAt["ctr"]; !ok || !at.Equal(start) {
+				t.Errorf("expected ctr to be remembered as stopped at %v but got %v", start, at)
+			}
+
+			clk.Step(time.Minute)
+			if err := impl.Thaw(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected thaw to succeed but failed: %v", err)
+			}
+			if got := impl.Frozen(); len(got) != 0 {
+				t.Errorf("expected no frozen pods but got %+v", got)
+			}
+			if len(dryRun.pausedAt) != 0 {
+				t.Errorf("expected no stopped containers to be remembered but got %v", dryRun.pausedAt)
+			}
+
+			var calls []string
+			switch fake := c.cri.(type) {
+			case *recordingCRI:
+				calls = fake.getCalls()
+			case throttlingCRI:
+				calls = fake.getCalls()
+			}
+			if len(calls) != 0 {
+				t.Errorf("expected the runtime not to be called but got %v", calls)
+			}
+		})
+	}
+}
+
+func dryRunCount(t *testing.T, mode, action string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(dryRunOperationsM.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		var modeMatches, actionMatches bool
+		for _, tag := range row.Tags {
+			modeMatches = modeMatches || (tag.Key == modeKey && tag.Value == mode)
+			actionMatches = actionMatches || (tag.Key == actionKey && tag.Value == action)
+		}
+		if modeMatches && actionMatches {
+			return row.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+func TestDryRunThawUnfrozen(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	dryRun := newDryRunCRI(newRecordingCRI(), ltesting.TestLogger(t), clk)
+	impl := &ContainerRuntimeImpl{cri: dryRun, clock: clk}
+	before := dryRunCount(t, ModeFreeze, dryRunStart)
+
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if got := dryRunCount(t, ModeFreeze, dryRunStart); got != before {
+		t.Errorf("expected no resumes to be counted for a pod which was not frozen but got %d", got-before)
+	}
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	if got := dryRunCount(t, ModeFreeze, dryRunStart); got != before+1 {
+		t.Errorf("expected one resume to be counted but got %d", got-before)
+	}
+}
@@ -0,0 +1,33 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// GRPCCode is the gRPC status code a failed freeze or thaw is returned to
+// callers of the daemon's gRPC API with
+func GRPCCode(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, ErrStuckFreeze):
+		return codes.Aborted
+	case errors.Is(err, common.ErrCircuitOpen):
+		return codes.Unavailable
+	case errors.Is(err, ErrStateMismatch), errors.Is(err, ErrUnknownRuntimeHandler):
+		return codes.FailedPrecondition
+	case errors.Is(err, common.ErrNoNonQueueProxyPods):
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,89 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// breakerCRI pauses containers through a runtime its resilience gave up on
+type breakerCRI struct {
+	FakeContainerdCRI
+	resilience *common.Resilience
+}
+
+func (b *breakerCRI) Pause(ctx context.Context, container string) error {
+	return b.resilience.Do(ctx, common.IsTransientNet, func(ctx context.Context) error {
+		return b.FakeContainerdCRI.Pause(ctx, container)
+	})
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{{
+		name: "no error",
+		want: codes.OK,
+	}, {
+		name: "timeout",
+		err:  fmt.Errorf("failed to pause: %w", ErrTimeout),
+		want: codes.DeadlineExceeded,
+	}, {
+		name: "cancelled",
+		err:  context.Canceled,
+		want: codes.Canceled,
+	}, {
+		name: "stuck",
+		err:  &StuckFreezeError{Container: "ctr", State: common.StatePausing},
+		want: codes.Aborted,
+	}, {
+		name: "state mismatch",
+		err:  &StateMismatchError{Container: "ctr", Want: common.StateRunning, Got: common.StatePaused},
+		want: codes.FailedPrecondition,
+	}, {
+		name: "runtime unavailable",
+		err:  fmt.Errorf("failed to list containers: %w", common.ErrCircuitOpen),
+		want: codes.Unavailable,
+	}, {
+		name: "no containers",
+		err:  common.ErrNoNonQueueProxyPods,
+		want: codes.NotFound,
+	}, {
+		name: "other",
+		err:  fmt.Errorf("boom"),
+		want: codes.Internal,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := GRPCCode(test.err); got != test.want {
+				t.Errorf("expected code %v but got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestGRPCCodeOpenBreaker(t *testing.T) {
+	resilience := &common.Resilience{Attempts: 1, Breaker: common.NewBreaker(1, time.Hour)}
+	resilience.Do(context.Background(), func(error) bool { return true }, func(ctx context.Context) error {
+		return errors.New("runtime down")
+	})
+
+	fake := &breakerCRI{resilience: resilience}
+	fake.containers = []*cri.Container{Container("ctr", "user-container")}
+	impl := &ContainerRuntimeImpl{cri: fake}
+
+	err := impl.Freeze(context.Background(), "pod")
+	if got, want := GRPCCode(err), codes.Unavailable; got != want {
+		t.Errorf("expected code %v for %v but got %v", want, err, got)
+	}
+}
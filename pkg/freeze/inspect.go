@@ -0,0 +1,134 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ActionFreeze = "freeze"
+	ActionThaw   = "thaw"
+)
+
+// maxRecentErrors is how many failed operations are remembered for inspection
+const maxRecentErrors = 50
+
+// FrozenPod is a pod the freezer has paused containers of
+type FrozenPod struct {
+	PodUID     string
+	Containers []string
+	FrozenAt   time.Time
+	// Mode is ModeFreeze or ModeThrottle
+	Mode string
+	// ReclaimedBytes is how much memory was reclaimed since the pod was frozen
+	ReclaimedBytes int64
+}
+
+// OperationError is an operation on a pod which failed
+type OperationError struct {
+	Time   time.Time
+	PodUID string
+	Action string
+	Error  string
+}
+
+// Frozen returns the pods the freezer has paused and not yet resumed, ordered
+// by when they were frozen
+func (c *ContainerRuntimeImpl) Frozen() []FrozenPod {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pods []FrozenPod
+	for name, st := range c.pods {
+		if len(st.paused) == 0 {
+			continue
+		}
+		pods = append(pods, frozenPodLocked(name, st))
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if !pods[i].FrozenAt.Equal(pods[j].FrozenAt) {
+			return pods[i].FrozenAt.Before(pods[j].FrozenAt)
+		}
+		return pods[i].PodUID < pods[j].PodUID
+	})
+	return pods
+}
+
+// Pod returns the pod if the freezer has paused and not yet resumed it
+func (c *ContainerRuntimeImpl) Pod(podUID string) (FrozenPod, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := c.pods[podUID]
+	if st == nil || len(st.paused) == 0 {
+		return FrozenPod{}, false
+	}
+	return frozenPodLocked(podUID, st), true
+}
+
+// frozenPodLocked describes a frozen pod. c.mu must be held.
+func frozenPodLocked(podUID string, st *podState) FrozenPod {
+	return FrozenPod{
+		PodUID:         podUID,
+		Containers:     append([]string(nil), st.paused...),
+		FrozenAt:       st.frozenAt,
+		Mode:           st.mode,
+		ReclaimedBytes: st.reclaimedBytes,
+	}
+}
+
+// RecentErrors returns the most recent failed operations, oldest first
+func (c *ContainerRuntimeImpl) RecentErrors() []OperationError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]OperationError(nil), c.errors...)
+}
+
+// ForceFreeze pauses the pod straight away, ignoring any pause delay or
+// minimum running time
+func (c *ContainerRuntimeImpl) ForceFreeze(ctx context.Context, podName string) error {
+	c.mu.Lock()
+	if st := c.pods[podName]; st != nil && st.pause != nil {
+		st.pause.Stop()
+		st.pause = nil
+	}
+	c.mu.Unlock()
+	return c.freeze(ctx, podName)
+}
+
+// ThawAll resumes every pod the freezer has paused. It carries on past pods
+// which fail to resume and returns an error naming them.
+func (c *ContainerRuntimeImpl) ThawAll(ctx context.Context) error {
+	var failed []string
+	for _, pod := range c.Frozen() {
+		if err := c.Thaw(ctx, pod.PodUID); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", pod.PodUID, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d pods not thawed: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// recordError remembers a failed operation, err may be nil
+func (c *ContainerRuntimeImpl) recordError(podName, action string, err error) {
+	if err == nil {
+		return
+	}
+	now := c.getClock().Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errors) == maxRecentErrors {
+		c.errors = append(c.errors[:0], c.errors[1:]...)
+	}
+	c.errors = append(c.errors, OperationError{
+		Time:   now,
+		PodUID: podName,
+		Action: action,
+		Error:  err.Error(),
+	})
+}
@@ -0,0 +1,126 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+// failingCRI fails to resume containers
+type failingCRI struct {
+	*recordingCRI
+}
+
+func (f failingCRI) Resume(ctx context.Context, container string) error {
+	return errors.New("boom")
+}
+
+func TestFrozenAndThawAll(t *testing.T) {
+	start := time.Now()
+	clk := clocktesting.NewFakeClock(start)
+	c := &ContainerRuntimeImpl{cri: newRecordingCRI(), clock: clk}
+
+	if err := c.Freeze(context.Background(), "pod-a"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	clk.Step(time.Minute)
+	if err := c.Freeze(context.Background(), "pod-b"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+
+	want := []FrozenPod{
+		{PodUID: "pod-a", Containers: []string{"ctr"}, FrozenAt: start, Mode: ModeFreeze},
+		{PodUID: "pod-b", Containers: []string{"ctr"}, FrozenAt: start.Add(time.Minute), Mode: ModeFreeze},
+	}
+	if got := c.Frozen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected frozen pods %+v but got %+v", want, got)
+	}
+	if got, ok := c.Pod("pod-b"); !ok || !reflect.DeepEqual(got, want[1]) {
+		t.Errorf("expected pod %+v but got %+v", want[1], got)
+	}
+
+	if err := c.ThawAll(context.Background()); err != nil {
+		t.Fatalf("expected thaw all to succeed but failed: %v", err)
+	}
+	if got := c.Frozen(); len(got) != 0 {
+		t.Errorf("expected no frozen pods but got %+v", got)
+	}
+	if got, ok := c.Pod("pod-b"); ok {
+		t.Errorf("expected pod to be thawed but got %+v", got)
+	}
+}
+
+func TestForceFreezeIgnoresPauseDelay(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	c := &ContainerRuntimeImpl{cri: fake, clock: clk, pauseDelay: time.Minute}
+
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := c.ForceFreeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected force freeze to succeed but failed: %v", err)
+	}
+	clk.Step(time.Minute)
+
+	if calls := fake.getCalls(); len(calls) != 1 || calls[0] != "pause" {
+		t.Errorf("expected a single pause but got %v", calls)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	c := &ContainerRuntimeImpl{cri: failingCRI{newRecordingCRI()}, clock: clk}
+
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	for i := 0; i < maxRecentErrors+1; i++ {
+		if err := c.Thaw(context.Background(), "pod"); err == nil {
+			t.Fatal("expected thaw to fail")
+		}
+	}
+	if err := c.ThawAll(context.Background()); err == nil {
+		t.Error("expected thaw all to fail")
+	}
+
+	errs := c.RecentErrors()
+	if len(errs) != maxRecentErrors {
+		t.Fatalf("expected %d errors to be kept but got %d", maxRecentErrors, len(errs))
+	}
+	if e := errs[0]; e.PodUID != "pod" || e.Action != ActionThaw || e.Error != "ctr not resumed: boom" {
+		t.Errorf("unexpected error recorded: %+v", e)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	start := time.Now()
+	clk := clocktesting.NewFakeClock(start)
+	var got []Transition
+	c := &ContainerRuntimeImpl{cri: newRecordingCRI(), clock: clk}
+	WithTransitionFunc(func(t Transition) { got = append(got, t) })(c)
+
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	// Freezing a frozen pod changes nothing
+	if err := c.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	clk.Step(time.Minute)
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+
+	want := []Transition{
+		{PodUID: "pod", Action: ActionFreeze, Started: start},
+		{PodUID: "pod", Action: ActionThaw, Started: start.Add(time.Minute), FrozenFor: time.Minute},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected transitions %+v but got %+v", want, got)
+	}
+}
@@ -0,0 +1,143 @@
+package freeze
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	suppressedTransitionsM = stats.Int64(
+		"suppressed_transitions",
+		"Number of pause and resume transitions which were delayed or not sent to the runtime",
+		stats.UnitDimensionless)
+
+	reclaimedBytesM = stats.Int64(
+		"reclaimed_bytes",
+		"Bytes of memory reclaimed from frozen pods",
+		stats.UnitBytes)
+
+	dryRunOperationsM = stats.Int64(
+		"dry_run_operations",
+		"Number of containers which would have been stopped or started without dry-run",
+		stats.UnitDimensionless)
+
+	dryRunStoppedSecondsM = stats.Float64(
+		"dry_run_stopped_seconds",
+		"How long containers would have been stopped for without dry-run",
+		stats.UnitSeconds)
+
+	stuckFreezesM = stats.Int64(
+		"stuck_freezes",
+		"Number of containers which did not reach the paused state in time and whose pod was thawed",
+		stats.UnitDimensionless)
+
+	stateMismatchesM = stats.Int64(
+		"state_mismatches",
+		"Number of containers found in another state than the freezer left them in",
+		stats.UnitDimensionless)
+
+	reasonKey = tag.MustNewKey("reason")
+	modeKey   = tag.MustNewKey("mode")
+	actionKey = tag.MustNewKey("action")
+)
+
+const (
+	// reasonPauseCancelled is recorded when a resume arrives while a pause is still delayed
+	reasonPauseCancelled = "pause_cancelled"
+	// reasonPauseCoalesced is recorded when a pause arrives while one is already delayed
+	reasonPauseCoalesced = "pause_coalesced"
+	// reasonPauseDeferred is recorded when a pause is delayed to give a thawed pod its minimum running time
+	reasonPauseDeferred = "pause_deferred"
+	// reasonSuperseded is recorded when a request waiting for another on the same pod is overtaken by a newer one
+	reasonSuperseded = "superseded"
+
+	// mismatchNotResumed is recorded when a container is not running after being resumed
+	mismatchNotResumed = "not_resumed"
+	// mismatchResumedExternally is recorded when the audit finds a frozen container running
+	mismatchResumedExternally = "resumed_externally"
+	// mismatchPausedExternally is recorded when the audit finds a container of a thawed pod paused
+	mismatchPausedExternally = "paused_externally"
+	// mismatchExited is recorded when a frozen container exits or is deleted
+	mismatchExited = "exited_while_frozen"
+
+	dryRunStop  = "stop"
+	dryRunStart = "start"
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: "Number of pause and resume transitions which were delayed or not sent to the runtime",
+		Measure:     suppressedTransitionsM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{reasonKey},
+	}, &view.View{
+		Description: "Bytes of memory reclaimed from frozen pods",
+		Measure:     reclaimedBytesM,
+		Aggregation: view.Sum(),
+	}, &view.View{
+		Description: "Number of containers which would have been stopped or started without dry-run",
+		Measure:     dryRunOperationsM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{modeKey, actionKey},
+	}, &view.View{
+		Description: "How long containers would have been stopped for without dry-run",
+		Measure:     dryRunStoppedSecondsM,
+		Aggregation: view.Distribution(1, 10, 60, 300, 900, 3600, 4*3600, 24*3600),
+		TagKeys:     []tag.Key{modeKey},
+	}, &view.View{
+		Description: "Number of containers which did not reach the paused state in time and whose pod was thawed",
+		Measure:     stuckFreezesM,
+		Aggregation: view.Count(),
+	}, &view.View{
+		Description: "Number of containers found in another state than the freezer left them in",
+		Measure:     stateMismatchesM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{reasonKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func recordSuppressed(reason string) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(reasonKey, reason))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, suppressedTransitionsM.M(1))
+}
+
+func recordReclaimed(bytes int64) {
+	stats.Record(context.Background(), reclaimedBytesM.M(bytes))
+}
+
+func recordDryRun(mode, action string) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(modeKey, mode), tag.Upsert(actionKey, action))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, dryRunOperationsM.M(1))
+}
+
+func recordDryRunStopped(mode string, d time.Duration) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(modeKey, mode))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, dryRunStoppedSecondsM.M(d.Seconds()))
+}
+
+func recordStuckFreeze() {
+	stats.Record(context.Background(), stuckFreezesM.M(1))
+}
+
+func recordStateMismatch(reason string) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(reasonKey, reason))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, stateMismatchesM.M(1))
+}
@@ -3,81 +3,383 @@ package freeze
 import (
 	"context"
 	"errors"
-	"fmt"
+	"sync"
+	"time"
 
-	"knative.dev/container-freezer/pkg/freeze/common"
-	"knative.dev/container-freezer/pkg/freeze/containerd"
-	"knative.dev/container-freezer/pkg/freeze/crio"
-)
+	"go.uber.org/zap"
+	"k8s.io/utils/clock"
 
-const (
-	runtimeTypeContainerd = "containerd"
-	runtimeTypeCrio       = "crio"
+	"knative.dev/container-freezer/pkg/freeze/common"
 )
 
 type CRI interface {
 	List(ctx context.Context, podUID string) ([]string, error)
 	Pause(ctx context.Context, container string) error
 	Resume(ctx context.Context, container string) error
+	// Status reports the state of a container, runtimes which cannot return
+	// common.ErrStatusUnsupported
+	Status(ctx context.Context, container string) (common.ContainerState, error)
 }
 
 type ContainerRuntimeImpl struct {
-	cri CRI
+	cri           CRI
+	capabilities  Capabilities
+	address       string
+	stateDir      string
+	handlers      []RuntimeHandler
+	timeouts      Timeouts
+	verifyTimeout time.Duration
+	stuckFinder   StuckProcessFinder
+
+	pauseDelay      time.Duration
+	minRunDuration  time.Duration
+	logger          *zap.SugaredLogger
+	clock           clock.WithDelayedExecution
+	pool            *pool
+	onTransition    TransitionFunc
+	onChange        ChangeFunc
+	checkpointAfter time.Duration
+	reclaimAfter    time.Duration
+	reclaimer       MemoryReclaimer
+	mode            string
+	dryRun          bool
+
+	mu     sync.Mutex
+	pods   map[string]*podState
+	gen    uint64
+	errors []OperationError
 }
 
-// NewCRIProvider returns a provider to thaw/freeze based on container-runtime
-func NewCRIProvider(runtimeType string) (*ContainerRuntimeImpl, error) {
-	criImpl := &ContainerRuntimeImpl{}
+// Option configures a ContainerRuntimeImpl
+type Option func(*ContainerRuntimeImpl)
 
-	switch runtimeType {
-	case runtimeTypeContainerd:
-		containerdImpl, err := containerd.NewContainerdProvider()
-		if err != nil {
-			return nil, err
+// WithPauseDelay delays pauses by d, a resume arriving within d cancels the pause
+func WithPauseDelay(d time.Duration) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.pauseDelay = d
+	}
+}
+
+// WithMinRunDuration keeps a pod running for at least d after it was thawed
+// before it can be paused again, earlier pauses are delayed until then
+func WithMinRunDuration(d time.Duration) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.minRunDuration = d
+	}
+}
+
+// WithMaxConcurrentOperations limits how many pods are paused or resumed at
+// once across the node. Waiting resumes always go ahead of waiting pauses.
+func WithMaxConcurrentOperations(n int) Option {
+	return func(c *ContainerRuntimeImpl) {
+		if n > 0 {
+			c.pool = newPool(n)
 		}
-		criImpl.cri = containerdImpl
-		return criImpl, err
-	case runtimeTypeCrio:
-		crioImpl, err := crio.NewCrioProvider()
-		if err != nil {
+	}
+}
+
+// WithLogger sets the logger used for work done in the background
+func WithLogger(logger *zap.SugaredLogger) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.logger = logger
+	}
+}
+
+// WithRuntimeAddress sets where the container runtime listens, by default
+// each backend uses the usual address of its runtime
+func WithRuntimeAddress(address string) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.address = address
+	}
+}
+
+// WithStateDir sets where backends keep what must survive restarts of the
+// daemon, such as the original resources of throttled containers
+func WithStateDir(dir string) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.stateDir = dir
+	}
+}
+
+// NewCRIProvider returns a provider to thaw/freeze through the backend
+// registered as runtimeType
+func NewCRIProvider(runtimeType string, opts ...Option) (*ContainerRuntimeImpl, error) {
+	criImpl := &ContainerRuntimeImpl{}
+	for _, opt := range opts {
+		opt(criImpl)
+	}
+
+	backendOpts := BackendOptions{
+		Address:  criImpl.address,
+		Logger:   criImpl.getLogger(),
+		StateDir: criImpl.stateDir,
+	}
+	cri, capabilities, err := newBackend(runtimeType, backendOpts)
+	if err != nil {
+		return nil, err
+	}
+	if len(criImpl.handlers) > 0 {
+		if cri, capabilities, err = newRoutingCRI(cri, capabilities, runtimeType, criImpl.address, criImpl.handlers, backendOpts); err != nil {
 			return nil, err
 		}
-		criImpl.cri = crioImpl
-		return criImpl, err
-	default:
-		return nil, fmt.Errorf("unrecognised runtimeType:%s", runtimeType)
 	}
+	criImpl.cri = cri
+	criImpl.capabilities = capabilities
+
+	if criImpl.dryRun {
+		criImpl.cri = newDryRunCRI(criImpl.cri, criImpl.getLogger(), criImpl.getClock())
+		criImpl.capabilities.Checkpoint = false
+		criImpl.reclaimer = nil
+	} else if w, ok := criImpl.cri.(EventWatcher); ok {
+		w.WatchEvents(criImpl.containerChanged)
+	}
+	return criImpl, nil
+}
+
+// Capabilities returns what the backend in use supports
+func (c *ContainerRuntimeImpl) Capabilities() Capabilities {
+	return c.capabilities
 }
 
-// Freeze performs a pause action based on different container-runtime
+// Freeze performs a pause action based on different container-runtime. If a
+// pause delay or minimum running time applies the pause happens in the
+// background and Freeze returns immediately.
 func (c *ContainerRuntimeImpl) Freeze(ctx context.Context, podName string) error {
-	containerIDs, err := c.cri.List(ctx, podName)
-	if err != nil {
-		if errors.Is(err, common.ErrNoNonQueueProxyPods) {
-			return nil
-		}
-		return err
+	return c.requestFreeze(ctx, podName, 0)
+}
+
+// QueueFreeze registers a freeze of the pod and returns the function which
+// performs it, for callers which answer before the pod is frozen. A thaw
+// registered in between supersedes the freeze, which then does nothing, so
+// the thaw wins however late the freeze runs. The returned function must be
+// called exactly once.
+func (c *ContainerRuntimeImpl) QueueFreeze(podName string) func(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.podStateLocked(podName)
+	gen := c.nextGenLocked()
+	st.latest = gen
+	// The queued request keeps the state of the pod until it runs
+	st.waiters++
+	return func(ctx context.Context) error {
+		return c.requestFreeze(ctx, podName, gen)
 	}
+}
 
-	for _, ctr := range containerIDs {
-		if err := c.cri.Pause(ctx, ctr); err != nil {
-			return fmt.Errorf("%s not paused: %v", ctr, err)
-		}
+// requestFreeze is Freeze for the request queued as gen by QueueFreeze, or
+// a new request if gen is zero
+func (c *ContainerRuntimeImpl) requestFreeze(ctx context.Context, podName string, gen uint64) error {
+	if c.schedulePause(podName, gen) {
+		return nil
 	}
-	return nil
+	return c.freezeAs(ctx, podName, gen)
 }
 
 // Thaw performs a resume action based on different container-runtime
 func (c *ContainerRuntimeImpl) Thaw(ctx context.Context, podName string) error {
-	containerIDs, err := c.cri.List(ctx, podName)
-	if err != nil {
+	if c.cancelPause(podName) {
+		c.changed(podName)
+		return nil
+	}
+	if err := c.thaw(ctx, podName); err != nil {
 		return err
 	}
+	c.thawed(podName)
+	return nil
+}
+
+// freeze pauses the containers of the pod which the freezer has not already
+// paused. It stops between containers if a resume for the pod arrives, rather
+// than cancelling a runtime call which may still take effect.
+func (c *ContainerRuntimeImpl) freeze(ctx context.Context, podName string) error {
+	return c.freezeAs(ctx, podName, 0)
+}
+
+// freezeAs is freeze for the request queued as gen, or a new one if gen is
+// zero
+func (c *ContainerRuntimeImpl) freezeAs(ctx context.Context, podName string, gen uint64) error {
+	start := c.getClock().Now()
+	paused := 0
+	err := c.serializeAs(ctx, podName, gen, true, func(st *podState) error {
+		superseded := st.superseded
+		release, err := c.pool.acquire(ctx, false, superseded)
+		if errors.Is(err, errPoolCancelled) {
+			recordSuppressed(reasonSuperseded)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		containerIDs, err := c.list(ctx, podName)
+		if err != nil {
+			if errors.Is(err, common.ErrNoNonQueueProxyPods) {
+				return nil
+			}
+			return err
+		}
+
+		c.mu.Lock()
+		mode := st.mode
+		c.mu.Unlock()
+		if mode == "" {
+			if mode, err = c.listMode(ctx, podName); err != nil {
+				return err
+			}
+		}
+
+		c.mu.Lock()
+		st.tracked = true
+		st.mode = mode
+		alreadyPaused := append([]string(nil), st.paused...)
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			st.stopping = ""
+			c.mu.Unlock()
+		}()
 
-	for _, ctr := range containerIDs {
-		if err := c.cri.Resume(ctx, ctr); err != nil {
-			return fmt.Errorf("%s not resumed: %v", ctr, err)
+		for _, ctr := range containerIDs {
+			if contains(alreadyPaused, ctr) {
+				continue
+			}
+			select {
+			case <-superseded:
+				recordSuppressed(reasonSuperseded)
+				return nil
+			default:
+			}
+			// The container stays attributed to the pod until it is recorded
+			// as paused or rolled back, so that the events of the pause are
+			// known to be ours
+			c.mu.Lock()
+			st.stopping = ctr
+			c.mu.Unlock()
+			expired, err := c.stop(ctx, mode, ctr)
+			if expired {
+				return c.timedOut(podName, st, mode, ctr)
+			} else if err != nil {
+				return err
+			}
+			if mode == ModeFreeze {
+				if state, ok := c.verifyPaused(ctx, ctr); !ok {
+					return c.stuck(podName, st, mode, ctr, state)
+				}
+			}
+			c.mu.Lock()
+			st.stopping = ""
+			if len(st.paused) == 0 {
+				st.frozenAt = c.getClock().Now()
+			}
+			st.paused = append(st.paused, ctr)
+			c.mu.Unlock()
+			paused++
 		}
+
+		c.mu.Lock()
+		if len(st.paused) > 0 {
+			c.scheduleCheckpointLocked(podName, st)
+			c.scheduleReclaimLocked(podName, st)
+		}
+		c.mu.Unlock()
+		return nil
+	})
+	c.finished(Transition{PodUID: podName, Action: ActionFreeze, Started: start, Err: err}, paused > 0)
+	return err
+}
+
+// thaw resumes the containers of the pod the freezer paused, or all of them
+// if the freezer does not know which were paused.
+func (c *ContainerRuntimeImpl) thaw(ctx context.Context, podName string) error {
+	start := c.getClock().Now()
+	resumed := 0
+	var frozenAt time.Time
+	err := c.serialize(ctx, podName, false, func(st *podState) error {
+		release, err := c.pool.acquire(ctx, true, nil)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		c.mu.Lock()
+		cancelCheckpointLocked(st)
+		cancelReclaimLocked(st)
+		tracked := st.tracked
+		mode := st.mode
+		containerIDs := append([]string(nil), st.paused...)
+		checkpointed := append([]string(nil), st.checkpointed...)
+		frozenAt = st.frozenAt
+		c.mu.Unlock()
+
+		if !tracked {
+			if containerIDs, err = c.list(ctx, podName); err != nil {
+				return err
+			}
+			if mode, err = c.listMode(ctx, podName); err != nil {
+				return err
+			}
+			// A daemon which restarted since the pod was checkpointed
+			// restores its containers all the same
+			if checkpointed = c.loadCheckpointed(podName); len(checkpointed) > 0 {
+				mode = ModeFreeze
+				c.mu.Lock()
+				st.checkpointed = append([]string(nil), checkpointed...)
+				c.mu.Unlock()
+			}
+		}
+
+		for _, ctr := range containerIDs {
+			restore := contains(checkpointed, ctr)
+			if err := c.start(ctx, mode, ctr, restore); err != nil {
+				return err
+			}
+			// A container still paused stays recorded as such, so that thawing
+			// the pod again retries it
+			if mode == ModeFreeze && !restore {
+				if err := c.verifyResumed(ctx, ctr); err != nil {
+					return err
+				}
+			}
+			c.mu.Lock()
+			if restore {
+				st.checkpointed = remove(st.checkpointed, ctr)
+				c.saveCheckpointedLocked(podName, st)
+			}
+			st.paused = remove(st.paused, ctr)
+			if len(st.paused) == 0 {
+				st.frozenAt = time.Time{}
+				st.reclaimedBytes = 0
+				st.mode = ""
+			}
+			c.mu.Unlock()
+			resumed++
+		}
+		return nil
+	})
+	t := Transition{PodUID: podName, Action: ActionThaw, Started: start, Err: err}
+	if !frozenAt.IsZero() {
+		t.FrozenFor = start.Sub(frozenAt)
 	}
-	return nil
+	c.finished(t, resumed > 0)
+	return err
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
 }
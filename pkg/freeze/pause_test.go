@@ -7,6 +7,7 @@ import (
 
 	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 	"knative.dev/container-freezer/pkg/daemon"
+	"knative.dev/container-freezer/pkg/freeze/common"
 )
 
 type FakeContainerdCRI struct {
@@ -48,6 +49,10 @@ func (f *FakeContainerdCRI) Resume(ctx context.Context, container string) error
 	return nil
 }
 
+func (f *FakeContainerdCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	return common.StateUnknown, common.ErrStatusUnsupported
+}
+
 func TestContainerPause(t *testing.T) {
 	var fakeFreezeThawer daemon.FreezeThawer
 
@@ -71,7 +76,7 @@ func TestContainerPause(t *testing.T) {
 			containers: c.containers,
 		}
 		fakeFreezeThawer = &ContainerRuntimeImpl{cri: fakeContainerCRI}
-		if err := fakeFreezeThawer.Freeze(nil, ""); err != nil {
+		if err := fakeFreezeThawer.Freeze(context.Background(), ""); err != nil {
 			t.Errorf("expected freeze to succeed but failed: %v", err)
 		}
 		if !reflect.DeepEqual(fakeContainerCRI.paused, c.expectedPause) {
@@ -106,7 +111,7 @@ func TestContainerResume(t *testing.T) {
 			containers: c.containers,
 		}
 		fakeFreezeThawer = &ContainerRuntimeImpl{cri: fakeContainerdCRI}
-		if err := fakeFreezeThawer.Thaw(nil, ""); err != nil {
+		if err := fakeFreezeThawer.Thaw(context.Background(), ""); err != nil {
 			t.Errorf("expected thaw to succeed but failed: %v", err)
 		}
 		if !reflect.DeepEqual(fakeContainerdCRI.resumed, c.expectedResume) {
@@ -0,0 +1,10 @@
+// Package api holds the gRPC protocol spoken between the freezer and
+// out-of-process backend plugins.
+package api
+
+// The code is generated with protoc-gen-go v1.28.0 and protoc-gen-go-grpc v1.2.0:
+//
+//	go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.28.0
+//	go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.2.0
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative plugin.proto
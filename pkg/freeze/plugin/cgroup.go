@@ -0,0 +1,35 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"knative.dev/container-freezer/pkg/freeze/cgroup"
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// CgroupBackend is the reference plugin. It finds the containers of a pod
+// through the CRI, leaving queue-proxy out as the built-in backends do, and
+// freezes them through cgroup v2 rather than the runtime.
+type CgroupBackend struct {
+	// CRI is a connection to the CRI runtime service
+	CRI     *grpc.ClientConn
+	Freezer *cgroup.Freezer
+}
+
+func (b *CgroupBackend) List(ctx context.Context, podUID string) ([]string, error) {
+	return common.List(ctx, b.CRI, podUID)
+}
+
+func (b *CgroupBackend) Pause(ctx context.Context, container string) error {
+	return b.Freezer.Pause(ctx, container)
+}
+
+func (b *CgroupBackend) Resume(ctx context.Context, container string) error {
+	return b.Freezer.Resume(ctx, container)
+}
+
+func (b *CgroupBackend) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	return b.Freezer.Status(ctx, container)
+}
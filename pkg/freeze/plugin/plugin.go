@@ -0,0 +1,160 @@
+// Package plugin runs freezer backends out of process. A plugin serves the
+// Backend gRPC service of package api on a unix socket, and the freezer uses
+// it as the "plugin" runtime with its address pointing at that socket.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"knative.dev/container-freezer/pkg/freeze"
+	"knative.dev/container-freezer/pkg/freeze/common"
+	"knative.dev/container-freezer/pkg/freeze/plugin/api"
+)
+
+// RuntimeType selects the plugin backend
+const RuntimeType = "plugin"
+
+func init() {
+	freeze.Register(RuntimeType, func(opts freeze.BackendOptions) (freeze.CRI, error) {
+		if opts.Address == "" {
+			return nil, errors.New("the plugin runtime needs the address of the plugin's socket")
+		}
+		return Dial(opts.Address)
+	}, freeze.Capabilities{})
+}
+
+// sentinels are the errors of the freezer which a plugin reports with their
+// own status code, so that the client can return them again
+var sentinels = []struct {
+	err  error
+	code codes.Code
+}{
+	{common.ErrNoNonQueueProxyPods, codes.NotFound},
+	{common.ErrStatusUnsupported, codes.Unimplemented},
+}
+
+var states = map[api.ContainerState]common.ContainerState{
+	api.ContainerState_CONTAINER_STATE_UNKNOWN: common.StateUnknown,
+	api.ContainerState_CONTAINER_STATE_RUNNING: common.StateRunning,
+	api.ContainerState_CONTAINER_STATE_PAUSED:  common.StatePaused,
+	api.ContainerState_CONTAINER_STATE_STOPPED: common.StateStopped,
+	api.ContainerState_CONTAINER_STATE_PAUSING: common.StatePausing,
+}
+
+// Client is a freezer backend talking to a plugin
+type Client struct {
+	conn    *grpc.ClientConn
+	backend api.BackendClient
+	status  bool
+}
+
+// Dial returns a client of the plugin listening on the unix socket at
+// address, which may carry a unix:// prefix, and asks the plugin what it
+// supports. Plugins which predate the question are taken to support nothing
+// but the CRI interface.
+func Dial(address string) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	opts := append([]grpc.DialOption{grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+	})}, common.NewResilience().DialOptions()...)
+	conn, err := grpc.DialContext(ctx, strings.TrimPrefix(address, "unix://"), opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, backend: api.NewBackendClient(conn)}
+	caps, err := c.backend.Capabilities(ctx, &api.CapabilitiesRequest{})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+	case err != nil:
+		conn.Close()
+		return nil, err
+	default:
+		c.status = caps.Status
+	}
+	return c, nil
+}
+
+// Capabilities returns what the plugin reported to support
+func (c *Client) Capabilities() freeze.Capabilities {
+	return freeze.Capabilities{Status: c.status}
+}
+
+// Close closes the connection to the plugin
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) List(ctx context.Context, podUID string) ([]string, error) {
+	resp, err := c.backend.List(ctx, &api.ListRequest{PodUid: podUID})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp.ContainerIds, nil
+}
+
+func (c *Client) Pause(ctx context.Context, container string) error {
+	_, err := c.backend.Pause(ctx, &api.PauseRequest{ContainerId: container})
+	return fromStatus(err)
+}
+
+func (c *Client) Resume(ctx context.Context, container string) error {
+	_, err := c.backend.Resume(ctx, &api.ResumeRequest{ContainerId: container})
+	return fromStatus(err)
+}
+
+func (c *Client) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	if !c.status {
+		return common.StateUnknown, common.ErrStatusUnsupported
+	}
+	resp, err := c.backend.Status(ctx, &api.StatusRequest{ContainerId: container})
+	if err != nil {
+		return common.StateUnknown, fromStatus(err)
+	}
+	return states[resp.State], nil
+}
+
+// fromStatus returns the error a plugin reported as is, rather than wrapped
+// in a gRPC status, and the sentinel it stands for if it has one. Errors of
+// the connection keep their status.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Code() == codes.Unknown {
+		return errors.New(s.Message())
+	}
+	for _, sentinel := range sentinels {
+		if s.Code() == sentinel.code {
+			return &pluginError{msg: s.Message(), err: sentinel.err}
+		}
+	}
+	return err
+}
+
+// pluginError is an error a plugin reported for a sentinel of the freezer
+type pluginError struct {
+	msg string
+	err error
+}
+
+func (e *pluginError) Error() string {
+	return e.msg
+}
+
+func (e *pluginError) Unwrap() error {
+	return e.err
+}
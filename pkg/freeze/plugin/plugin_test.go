@@ -0,0 +1,235 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"knative.dev/container-freezer/pkg/freeze"
+	"knative.dev/container-freezer/pkg/freeze/cgroup"
+	"knative.dev/container-freezer/pkg/freeze/common"
+	"knative.dev/container-freezer/pkg/freeze/plugin/api"
+	"knative.dev/container-freezer/pkg/freeze/test"
+)
+
+// fakeBackend knows a single pod, and one running only queue-proxy, and fails
+// for any other
+type fakeBackend struct {
+	calls []string
+	state common.ContainerState
+}
+
+func (f *fakeBackend) List(ctx context.Context, podUID string) ([]string, error) {
+	switch podUID {
+	case "pod1":
+		return []string{"ctr1", "ctr2"}, nil
+	case "queue-proxy-only":
+		return nil, common.ErrNoNonQueueProxyPods
+	}
+	return nil, errors.New("pod not found")
+}
+
+func (f *fakeBackend) Pause(ctx context.Context, container string) error {
+	f.calls = append(f.calls, "pause "+container)
+	f.state = common.StatePaused
+	return nil
+}
+
+func (f *fakeBackend) Resume(ctx context.Context, container string) error {
+	f.calls = append(f.calls, "resume "+container)
+	f.state = common.StateRunning
+	return nil
+}
+
+func (f *fakeBackend) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	return f.state, nil
+}
+
+// noStatusBackend hides the Status method of the backend it wraps
+type noStatusBackend struct {
+	Backend
+}
+
+// serve serves b on a socket in a temporary directory and returns its address
+func serve(t *testing.T, b Backend) string {
+	t.Helper()
+	address := "unix://" + filepath.Join(t.TempDir(), "plugin.sock")
+	l, err := Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(b)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return address
+}
+
+func TestClient(t *testing.T) {
+	backend := &fakeBackend{state: common.StateRunning}
+	c, err := Dial(serve(t, backend))
+	if err != nil {
+		t.Fatalf("expected dial to succeed but failed: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	ids, err := c.List(ctx, "pod1")
+	if err != nil {
+		t.Fatalf("expected list to succeed but failed: %v", err)
+	}
+	if want := []string{"ctr1", "ctr2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected containers %v but got %v", want, ids)
+	}
+	if _, err := c.List(ctx, "pod2"); err == nil || err.Error() != "pod not found" {
+		t.Errorf("expected the plugin's error but got %v", err)
+	}
+
+	if err := c.Pause(ctx, "ctr1"); err != nil {
+		t.Fatalf("expected pause to succeed but failed: %v", err)
+	}
+	if state, err := c.Status(ctx, "ctr1"); err != nil || state != common.StatePaused {
+		t.Errorf("expected ctr1 to be paused but got %s, %v", state, err)
+	}
+	if err := c.Resume(ctx, "ctr1"); err != nil {
+		t.Fatalf("expected resume to succeed but failed: %v", err)
+	}
+	if state, err := c.Status(ctx, "ctr1"); err != nil || state != common.StateRunning {
+		t.Errorf("expected ctr1 to be running but got %s, %v", state, err)
+	}
+
+	if want := []string{"pause ctr1", "resume ctr1"}; !reflect.DeepEqual(backend.calls, want) {
+		t.Errorf("expected calls %v but got %v", want, backend.calls)
+	}
+}
+
+func TestClientSentinels(t *testing.T) {
+	c, err := Dial(serve(t, noStatusBackend{&fakeBackend{}}))
+	if err != nil {
+		t.Fatalf("expected dial to succeed but failed: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if caps := c.Capabilities(); caps.Status {
+		t.Errorf("expected a plugin without Status not to report status but got %+v", caps)
+	}
+	if _, err := c.Status(ctx, "ctr1"); !errors.Is(err, common.ErrStatusUnsupported) {
+		t.Errorf("expected %v but got %v", common.ErrStatusUnsupported, err)
+	}
+	if _, err := c.List(ctx, "queue-proxy-only"); !errors.Is(err, common.ErrNoNonQueueProxyPods) {
+		t.Errorf("expected %v but got %v", common.ErrNoNonQueueProxyPods, err)
+	}
+	if _, err := c.backend.Status(ctx, &api.StatusRequest{ContainerId: "ctr1"}); !errors.Is(fromStatus(err), common.ErrStatusUnsupported) {
+		t.Errorf("expected the plugin to report %v but got %v", common.ErrStatusUnsupported, err)
+	}
+}
+
+func TestRegisteredBackend(t *testing.T) {
+	if _, err := freeze.NewCRIProvider(RuntimeType); err == nil {
+		t.Error("expected the plugin runtime to need an address")
+	}
+
+	backend := &fakeBackend{state: common.StateRunning}
+	impl, err := freeze.NewCRIProvider(RuntimeType, freeze.WithRuntimeAddress(serve(t, backend)))
+	if err != nil {
+		t.Fatalf("expected the plugin runtime to be created but failed: %v", err)
+	}
+	if caps := impl.Capabilities(); !caps.Status {
+		t.Errorf("expected the plugin runtime to report status but got %+v", caps)
+	}
+
+	if err := impl.Freeze(context.Background(), "pod1"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	if err := impl.Thaw(context.Background(), "pod1"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	want := []string{"pause ctr1", "pause ctr2", "resume ctr1", "resume ctr2"}
+	if !reflect.DeepEqual(backend.calls, want) {
+		t.Errorf("expected calls %v but got %v", want, backend.calls)
+	}
+
+	// A pod running nothing but queue-proxy is skipped as with built-in
+	// backends
+	if err := impl.Freeze(context.Background(), "queue-proxy-only"); err != nil {
+		t.Errorf("expected freezing a queue-proxy only pod to be skipped but failed: %v", err)
+	}
+
+	impl, err = freeze.NewCRIProvider(RuntimeType, freeze.WithRuntimeAddress(serve(t, noStatusBackend{backend})))
+	if err != nil {
+		t.Fatalf("expected the plugin runtime to be created but failed: %v", err)
+	}
+	if caps := impl.Capabilities(); caps.Status {
+		t.Errorf("expected a plugin without Status not to report status but got %+v", caps)
+	}
+}
+
+func TestCgroupBackend(t *testing.T) {
+	criSocketPath := test.GetRandomSocketPath()
+	criServer := test.NewCriRuntimeServer()
+	go test.RunCriServer(criServer, criSocketPath)
+	time.Sleep(time.Millisecond * 50)
+	criServer.AddPodSandboxForCRI(test.MockPod{
+		Id: "pod1",
+		Ctrs: []test.MockCtr{
+			{Id: "ctr1", Name: "user-container"},
+			{Id: "ctr2", Name: "queue-proxy"},
+		},
+	})
+
+	conn, err := grpc.Dial("unix://"+criSocketPath, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"cgroup.controllers":                             "cpu memory",
+		"kubepods/besteffort/podpod1/ctr1/cgroup.freeze": "0",
+		"kubepods/besteffort/podpod1/ctr2/cgroup.freeze": "0",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	address := serve(t, &CgroupBackend{CRI: conn, Freezer: cgroup.NewFreezer(root)})
+	impl, err := freeze.NewCRIProvider(RuntimeType, freeze.WithRuntimeAddress(address))
+	if err != nil {
+		t.Fatalf("expected the plugin runtime to be created but failed: %v", err)
+	}
+
+	if err := impl.Freeze(context.Background(), "pod1"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	assertFreeze(t, filepath.Join(root, "kubepods/besteffort/podpod1/ctr1"), "1")
+	assertFreeze(t, filepath.Join(root, "kubepods/besteffort/podpod1/ctr2"), "0")
+
+	if err := impl.Thaw(context.Background(), "pod1"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	assertFreeze(t, filepath.Join(root, "kubepods/besteffort/podpod1/ctr1"), "0")
+}
+
+func assertFreeze(t *testing.T, dir, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected %s/cgroup.freeze to be %q but got %q", dir, want, got)
+	}
+}
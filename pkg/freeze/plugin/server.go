@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+	"knative.dev/container-freezer/pkg/freeze/plugin/api"
+)
+
+// Backend is implemented by plugins, it is the CRI interface of the freezer
+type Backend interface {
+	List(ctx context.Context, podUID string) ([]string, error)
+	Pause(ctx context.Context, container string) error
+	Resume(ctx context.Context, container string) error
+}
+
+// StatusBackend is implemented by plugins which can report the state of
+// containers
+type StatusBackend interface {
+	Backend
+	Status(ctx context.Context, container string) (common.ContainerState, error)
+}
+
+// NewServer returns a gRPC server serving b to the freezer
+func NewServer(b Backend, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	api.RegisterBackendServer(s, &server{backend: b})
+	return s
+}
+
+// Listen listens on the unix socket at address, which may carry a unix://
+// prefix. A stale socket file is replaced, and a new socket is only
+// accessible by its owner.
+func Listen(address string) (net.Listener, error) {
+	path := strings.TrimPrefix(address, "unix://")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+type server struct {
+	api.UnimplementedBackendServer
+	backend Backend
+}
+
+func (s *server) List(ctx context.Context, req *api.ListRequest) (*api.ListResponse, error) {
+	ids, err := s.backend.List(ctx, req.PodUid)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.ListResponse{ContainerIds: ids}, nil
+}
+
+func (s *server) Pause(ctx context.Context, req *api.PauseRequest) (*api.PauseResponse, error) {
+	if err := s.backend.Pause(ctx, req.ContainerId); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.PauseResponse{}, nil
+}
+
+func (s *server) Resume(ctx context.Context, req *api.ResumeRequest) (*api.ResumeResponse, error) {
+	if err := s.backend.Resume(ctx, req.ContainerId); err != nil {
+		return nil, toStatus(err)
+	}
+	return &api.ResumeResponse{}, nil
+}
+
+func (s *server) Status(ctx context.Context, req *api.StatusRequest) (*api.StatusResponse, error) {
+	b, ok := s.backend.(StatusBackend)
+	if !ok {
+		return nil, toStatus(common.ErrStatusUnsupported)
+	}
+	state, err := b.Status(ctx, req.ContainerId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for apiState, st := range states {
+		if st == state {
+			return &api.StatusResponse{State: apiState}, nil
+		}
+	}
+	return &api.StatusResponse{State: api.ContainerState_CONTAINER_STATE_UNKNOWN}, nil
+}
+
+func (s *server) Capabilities(ctx context.Context, req *api.CapabilitiesRequest) (*api.CapabilitiesResponse, error) {
+	_, ok := s.backend.(StatusBackend)
+	return &api.CapabilitiesResponse{Status: ok}, nil
+}
+
+// toStatus gives the errors the freezer tells apart their own status code.
+// Other errors are sent as they are, which gRPC reports as unknown.
+func toStatus(err error) error {
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return status.Error(s.code, err.Error())
+		}
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errPoolCancelled is returned when waiting for a slot is abandoned because
+// the operation was superseded
+var errPoolCancelled = errors.New("waiting for a worker cancelled")
+
+// pool limits how many operations run against the runtime at once. Waiting
+// resumes are always given a slot before waiting pauses, so that a burst of
+// pauses, e.g. when many revisions scale to idle, never delays a cold start.
+type pool struct {
+	mu      sync.Mutex
+	size    int
+	running int
+	resumes []chan struct{}
+	pauses  []chan struct{}
+}
+
+func newPool(size int) *pool {
+	return &pool{size: size}
+}
+
+// acquire waits for a slot and returns a function releasing it. Waiting stops
+// with an error when ctx is done or cancel is closed.
+func (p *pool) acquire(ctx context.Context, resume bool, cancel <-chan struct{}) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+
+	p.mu.Lock()
+	if p.running < p.size && len(p.resumes) == 0 && (resume || len(p.pauses) == 0) {
+		p.running++
+		p.mu.Unlock()
+		return p.release, nil
+	}
+
+	granted := make(chan struct{})
+	if resume {
+		p.resumes = append(p.resumes, granted)
+	} else {
+		p.pauses = append(p.pauses, granted)
+	}
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case <-granted:
+		return p.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-cancel:
+		err = errPoolCancelled
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-granted:
+		// The slot was handed over while giving up, pass it on.
+		p.releaseLocked()
+	default:
+		p.resumes = removeWaiter(p.resumes, granted)
+		p.pauses = removeWaiter(p.pauses, granted)
+	}
+	return nil, err
+}
+
+func (p *pool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.releaseLocked()
+}
+
+// releaseLocked hands the slot to the next waiter, resumes first. p.mu must be held.
+func (p *pool) releaseLocked() {
+	switch {
+	case len(p.resumes) > 0:
+		close(p.resumes[0])
+		p.resumes = p.resumes[1:]
+	case len(p.pauses) > 0:
+		close(p.pauses[0])
+		p.pauses = p.pauses[1:]
+	default:
+		p.running--
+	}
+}
+
+func removeWaiter(waiters []chan struct{}, w chan struct{}) []chan struct{} {
+	for i, v := range waiters {
+		if v == w {
+			return append(waiters[:i], waiters[i+1:]...)
+		}
+	}
+	return waiters
+}
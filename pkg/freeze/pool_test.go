@@ -0,0 +1,126 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitForQueued(t *testing.T, p *pool, resumes, pauses int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		r, s := len(p.resumes), len(p.pauses)
+		p.mu.Unlock()
+		if r == resumes && s == pauses {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d resumes and %d pauses to queue, got %d and %d", resumes, pauses, r, s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolResumesGoFirst(t *testing.T) {
+	p := newPool(1)
+	release, err := p.acquire(context.Background(), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 3)
+	acquire := func(name string, resume bool) {
+		release, err := p.acquire(context.Background(), resume, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- name
+		release()
+	}
+
+	go acquire("pause", false)
+	waitForQueued(t, p, 0, 1)
+	go acquire("resume", true)
+	waitForQueued(t, p, 1, 1)
+
+	release()
+	if got := <-order; got != "resume" {
+		t.Errorf("expected the resume to be granted first but got %s", got)
+	}
+	if got := <-order; got != "pause" {
+		t.Errorf("expected the pause to be granted second but got %s", got)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running != 0 {
+		t.Errorf("expected all slots to be released but %d are running", p.running)
+	}
+}
+
+func TestPoolCancel(t *testing.T) {
+	p := newPool(1)
+	release, err := p.acquire(context.Background(), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel := make(chan struct{})
+	errCh := make(chan error)
+	go func() {
+		_, err := p.acquire(context.Background(), false, cancel)
+		errCh <- err
+	}()
+	waitForQueued(t, p, 0, 1)
+	close(cancel)
+	if err := <-errCh; !errors.Is(err, errPoolCancelled) {
+		t.Errorf("expected waiting to be cancelled but got %v", err)
+	}
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	go func() {
+		_, err := p.acquire(ctx, false, nil)
+		errCh <- err
+	}()
+	waitForQueued(t, p, 0, 1)
+	cancelCtx()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected waiting to stop with the context but got %v", err)
+	}
+
+	release()
+	if _, err := p.acquire(context.Background(), false, nil); err != nil {
+		t.Errorf("expected the slot to be free but got %v", err)
+	}
+}
+
+func TestMaxConcurrentOperations(t *testing.T) {
+	fake := newBlockingCRI()
+	c := &ContainerRuntimeImpl{cri: fake, pool: newPool(1)}
+
+	errs := make(chan error, 3)
+	go func() { errs <- c.Freeze(context.Background(), "pod1") }()
+	<-fake.pausing
+
+	go func() { errs <- c.Freeze(context.Background(), "pod2") }()
+	waitForQueued(t, c.pool, 0, 1)
+	go func() { errs <- c.Thaw(context.Background(), "pod3") }()
+	waitForQueued(t, c.pool, 1, 1)
+	close(fake.release)
+
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("expected request to succeed but failed: %v", err)
+		}
+	}
+
+	// pod3 is resumed before pod2 is paused, even though it was queued later
+	calls := fake.getCalls()
+	if len(calls) != 6 || calls[2] != "resume ctr1" || calls[4] != "pause ctr1" {
+		t.Errorf("expected pod1 to be paused, then pod3 resumed, then pod2 paused, but got %v", calls)
+	}
+}
@@ -0,0 +1,100 @@
+package freeze
+
+import (
+	"context"
+	"time"
+)
+
+const ActionReclaim = "reclaim"
+
+// MemoryReclaimer pushes the memory of containers out of RAM and returns how
+// many bytes it reclaimed
+type MemoryReclaimer interface {
+	Reclaim(ctx context.Context, podUID string, containerIDs []string) (int64, error)
+}
+
+// WithMemoryReclaim reclaims the memory of pods which have been frozen for d
+func WithMemoryReclaim(d time.Duration, r MemoryReclaimer) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.reclaimAfter = d
+		c.reclaimer = r
+	}
+}
+
+// scheduleReclaimLocked arranges for the memory of the frozen pod to be
+// reclaimed once it has been frozen long enough. c.mu must be held.
+func (c *ContainerRuntimeImpl) scheduleReclaimLocked(podName string, st *podState) {
+	if c.reclaimAfter <= 0 || c.reclaimer == nil || st.reclaim != nil || st.mode == ModeThrottle {
+		return
+	}
+	gen := c.nextGenLocked()
+	st.reclaimGen = gen
+	st.reclaim = c.getClock().AfterFunc(c.reclaimAfter, func() { go c.fireReclaim(podName, gen) })
+}
+
+// cancelReclaimLocked cancels a pending reclaim of the pod. c.mu must be held.
+func cancelReclaimLocked(st *podState) {
+	if st.reclaim != nil {
+		st.reclaim.Stop()
+		st.reclaim = nil
+	}
+}
+
+// fireReclaim reclaims the memory of the pod unless the reclaim has been cancelled
+func (c *ContainerRuntimeImpl) fireReclaim(podName string, gen uint64) {
+	c.mu.Lock()
+	st := c.pods[podName]
+	if st == nil || st.reclaim == nil || st.reclaimGen != gen {
+		c.mu.Unlock()
+		return
+	}
+	st.reclaim = nil
+	c.mu.Unlock()
+
+	if err := c.reclaim(context.Background(), podName); err != nil {
+		c.getLogger().Errorf("reclaiming memory of pod %s failed: %v", podName, err)
+	}
+}
+
+// reclaim reclaims the memory of the paused containers of the pod.
+// Checkpointed containers stay paused and hold their memory as well.
+func (c *ContainerRuntimeImpl) reclaim(ctx context.Context, podName string) error {
+	err := c.serialize(ctx, podName, true, func(st *podState) error {
+		c.mu.Lock()
+		containerIDs := append([]string(nil), st.paused...)
+		c.mu.Unlock()
+		if len(containerIDs) == 0 {
+			return nil
+		}
+
+		// A resume stops the reclaim rather than waiting for it, the memory
+		// is wanted back anyway
+		superseded := st.superseded
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-superseded:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		reclaimed, err := c.reclaimer.Reclaim(ctx, podName, containerIDs)
+		if reclaimed > 0 {
+			c.mu.Lock()
+			st.reclaimedBytes += reclaimed
+			c.mu.Unlock()
+			recordReclaimed(reclaimed)
+		}
+		select {
+		case <-superseded:
+			recordSuppressed(reasonSuperseded)
+			return nil
+		default:
+		}
+		return err
+	})
+	c.recordError(podName, ActionReclaim, err)
+	return err
+}
@@ -0,0 +1,143 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+type fakeReclaimer struct {
+	mu    sync.Mutex
+	calls [][]string
+	bytes int64
+	err   error
+}
+
+func (f *fakeReclaimer) Reclaim(ctx context.Context, podUID string, containerIDs []string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, containerIDs)
+	return f.bytes, f.err
+}
+
+func (f *fakeReclaimer) getCalls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.calls...)
+}
+
+func TestMemoryReclaim(t *testing.T) {
+	tests := []struct {
+		name          string
+		thawAfter     time.Duration
+		err           error
+		wantCalls     [][]string
+		wantReclaimed int64
+		wantErrors    int
+	}{{
+		name:          "reclaimed",
+		thawAfter:     time.Hour,
+		wantCalls:     [][]string{{"ctr"}},
+		wantReclaimed: 4096,
+	}, {
+		name:      "thawed before the reclaim",
+		thawAfter: time.Minute,
+	}, {
+		name:          "reclaim failed",
+		thawAfter:     time.Hour,
+		err:           errors.New("boom"),
+		wantCalls:     [][]string{{"ctr"}},
+		wantReclaimed: 4096,
+		wantErrors:    1,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			clk := clocktesting.NewFakeClock(time.Now())
+			reclaimer := &fakeReclaimer{bytes: 4096, err: c.err}
+			impl := &ContainerRuntimeImpl{cri: newRecordingCRI(), clock: clk}
+			WithMemoryReclaim(10*time.Minute, reclaimer)(impl)
+
+			if err := impl.Freeze(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected freeze to succeed but failed: %v", err)
+			}
+			clk.Step(c.thawAfter)
+			if len(c.wantCalls) > 0 {
+				deadline := time.Now().Add(5 * time.Second)
+				for len(impl.RecentErrors()) < c.wantErrors || len(reclaimer.getCalls()) == 0 {
+					if time.Now().After(deadline) {
+						t.Fatal("timed out waiting for the reclaim")
+					}
+					time.Sleep(time.Millisecond)
+				}
+				waitForIdle(t, impl, "pod")
+			}
+
+			frozen := impl.Frozen()
+			if len(frozen) != 1 || frozen[0].ReclaimedBytes != c.wantReclaimed {
+				t.Errorf("expected %d bytes reclaimed but got %+v", c.wantReclaimed, frozen)
+			}
+			if errs := impl.RecentErrors(); len(errs) != c.wantErrors {
+				t.Errorf("expected %d errors but got %+v", c.wantErrors, errs)
+			}
+
+			if err := impl.Thaw(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected thaw to succeed but failed: %v", err)
+			}
+			clk.Step(time.Hour)
+			if calls := reclaimer.getCalls(); !reflect.DeepEqual(calls, c.wantCalls) {
+				t.Errorf("expected reclaims %v but got %v", c.wantCalls, calls)
+			}
+			if len(impl.pods) != 0 {
+				t.Errorf("expected no pod state to be kept but got %d entries", len(impl.pods))
+			}
+		})
+	}
+}
+
+// blockingReclaimer reclaims until it is cancelled, like a reclaim of a large
+// container
+type blockingReclaimer struct {
+	started chan struct{}
+}
+
+func (b *blockingReclaimer) Reclaim(ctx context.Context, podUID string, containerIDs []string) (int64, error) {
+	close(b.started)
+	<-ctx.Done()
+	return 1024, ctx.Err()
+}
+
+func TestMemoryReclaimStoppedByThaw(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	fake := newRecordingCRI()
+	reclaimer := &blockingReclaimer{started: make(chan struct{})}
+	impl := &ContainerRuntimeImpl{cri: fake, clock: clk}
+	WithMemoryReclaim(10*time.Minute, reclaimer)(impl)
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but failed: %v", err)
+	}
+	clk.Step(10 * time.Minute)
+	select {
+	case <-reclaimer.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reclaim")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := impl.Thaw(ctx, "pod"); err != nil {
+		t.Fatalf("expected thaw to stop the reclaim and succeed but failed: %v", err)
+	}
+	if got, want := fake.getCalls(), []string{"pause", "resume"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+	if errs := impl.RecentErrors(); len(errs) != 0 {
+		t.Errorf("expected the stopped reclaim not to be recorded as failed but got %+v", errs)
+	}
+}
@@ -0,0 +1,117 @@
+package freeze
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Capabilities are what a backend supports besides listing, pausing and
+// resuming containers
+type Capabilities struct {
+	// Checkpoint is whether the backend implements Checkpointer
+	Checkpoint bool
+	// Throttle is whether the backend implements Throttler
+	Throttle bool
+	// Status is whether the backend reports the state of containers rather
+	// than returning common.ErrStatusUnsupported
+	Status bool
+}
+
+// CapabilityReporter is implemented by backends which only find out what they
+// support once they are created, for instance by asking a plugin. What they
+// report replaces the capabilities they were registered with.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// BackendOptions are passed to a BackendFactory
+type BackendOptions struct {
+	// Address is where the runtime listens, empty means the backend's default
+	Address string
+	Logger  *zap.SugaredLogger
+	// StateDir is where the backend keeps what must survive restarts of the
+	// daemon, empty means it keeps nothing
+	StateDir string
+}
+
+// BackendFactory creates a backend
+type BackendFactory func(opts BackendOptions) (CRI, error)
+
+type backend struct {
+	factory      BackendFactory
+	capabilities Capabilities
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]backend)
+)
+
+// Register makes a backend available to NewCRIProvider under name. It is
+// meant to be called from the init function of the package implementing the
+// backend, and panics if name is registered twice.
+func Register(name string, factory BackendFactory, capabilities Capabilities) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("freeze: Register factory is nil for backend " + name)
+	}
+	if _, dup := backends[name]; dup {
+		panic("freeze: Register called twice for backend " + name)
+	}
+	backends[name] = backend{factory: factory, capabilities: capabilities}
+}
+
+// Backends returns the names of the registered backends, sorted
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackendCapabilities returns the capabilities the backend registered under
+// name advertises
+func BackendCapabilities(name string) (Capabilities, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	b, ok := backends[name]
+	return b.capabilities, ok
+}
+
+// newBackend creates the backend registered under name and checks it
+// implements what it advertises
+func newBackend(name string, opts BackendOptions) (CRI, Capabilities, error) {
+	backendsMu.RLock()
+	b, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, Capabilities{}, fmt.Errorf("unrecognised runtimeType:%s, registered backends are %v", name, Backends())
+	}
+
+	cri, err := b.factory(opts)
+	if err != nil {
+		return nil, Capabilities{}, err
+	}
+	capabilities := b.capabilities
+	if r, ok := cri.(CapabilityReporter); ok {
+		capabilities = r.Capabilities()
+	}
+	if _, ok := cri.(Checkpointer); capabilities.Checkpoint && !ok {
+		return nil, Capabilities{}, fmt.Errorf("backend %s advertises checkpoint but does not implement it", name)
+	}
+	if _, ok := cri.(Throttler); capabilities.Throttle && !ok {
+		return nil, Capabilities{}, fmt.Errorf("backend %s advertises throttle but does not implement it", name)
+	}
+	return cri, capabilities, nil
+}
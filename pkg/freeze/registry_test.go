@@ -0,0 +1,97 @@
+package freeze
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// registeredOpts are the options the test-recording backend was created with
+var registeredOpts BackendOptions
+
+func init() {
+	Register("test-recording", func(opts BackendOptions) (CRI, error) {
+		registeredOpts = opts
+		return newRecordingCRI(), nil
+	}, Capabilities{})
+	Register("test-failing", func(opts BackendOptions) (CRI, error) {
+		return nil, errors.New("boom")
+	}, Capabilities{})
+	Register("test-lying", func(opts BackendOptions) (CRI, error) {
+		return newRecordingCRI(), nil
+	}, Capabilities{Throttle: true})
+	Register("test-throttling", func(opts BackendOptions) (CRI, error) {
+		return throttlingCRI{recordingCRI: newRecordingCRI()}, nil
+	}, Capabilities{Throttle: true})
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name             string
+		runtimeType      string
+		opts             []Option
+		wantErr          bool
+		wantCapabilities Capabilities
+	}{{
+		name:             "registered",
+		runtimeType:      "test-recording",
+		opts:             []Option{WithRuntimeAddress("/run/test.sock")},
+		wantCapabilities: Capabilities{},
+	}, {
+		name:             "capabilities",
+		runtimeType:      "test-throttling",
+		wantCapabilities: Capabilities{Throttle: true},
+	}, {
+		name:        "unknown",
+		runtimeType: "test-unknown",
+		wantErr:     true,
+	}, {
+		name:        "factory fails",
+		runtimeType: "test-failing",
+		wantErr:     true,
+	}, {
+		name:        "capability not implemented",
+		runtimeType: "test-lying",
+		wantErr:     true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			impl, err := NewCRIProvider(c.runtimeType, c.opts...)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr {
+				return
+			}
+			if got := impl.Capabilities(); got != c.wantCapabilities {
+				t.Errorf("expected capabilities %+v but got %+v", c.wantCapabilities, got)
+			}
+		})
+	}
+
+	if registeredOpts.Address != "/run/test.sock" || registeredOpts.Logger == nil {
+		t.Errorf("expected the address and a logger to be passed to the factory but got %+v", registeredOpts)
+	}
+
+	if caps, ok := BackendCapabilities(runtimeTypeContainerd); !ok || !caps.Checkpoint {
+		t.Errorf("expected containerd to advertise checkpoint but got %+v", caps)
+	}
+	want := []string{runtimeTypeContainerd, runtimeTypeCrio}
+	var got []string
+	for _, name := range Backends() {
+		if name == runtimeTypeContainerd || name == runtimeTypeCrio {
+			got = append(got, name)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the built-in backends %v to be registered but got %v", want, Backends())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a backend twice to panic")
+		}
+	}()
+	Register("test-recording", func(opts BackendOptions) (CRI, error) { return nil, nil }, Capabilities{})
+}
@@ -0,0 +1,251 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// ErrUnknownRuntimeHandler is returned for pods whose sandbox runs with a
+// RuntimeClass handler no backend is configured for. Nothing is paused.
+var ErrUnknownRuntimeHandler = errors.New("no backend configured for the runtime handler")
+
+// RuntimeHandlerResolver is implemented by runtimes which can look up the
+// RuntimeClass handler the sandbox of a pod runs with
+type RuntimeHandlerResolver interface {
+	RuntimeHandler(ctx context.Context, podUID string) (string, error)
+}
+
+// RuntimeHandler routes pods whose sandbox runs with Handler to the backend
+// registered as RuntimeType, listening at Address
+type RuntimeHandler struct {
+	Handler     string
+	RuntimeType string
+	Address     string
+}
+
+// WithRuntimeHandlers routes pods to backends by the RuntimeClass handler of
+// their sandbox, which is looked up through the default backend. Pods running
+// with the default handler go to the default backend, and pods running with a
+// handler not listed fail with ErrUnknownRuntimeHandler.
+func WithRuntimeHandlers(handlers ...RuntimeHandler) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.handlers = append(c.handlers, handlers...)
+	}
+}
+
+// ParseRuntimeHandlers parses a comma separated list of
+// handler=runtimeType[@address], e.g.
+// "kata=plugin@/var/run/kata-freezer.sock,runc=containerd"
+func ParseRuntimeHandlers(spec string) ([]RuntimeHandler, error) {
+	var handlers []RuntimeHandler
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		handler, backend, ok := strings.Cut(item, "=")
+		if !ok || handler == "" || backend == "" {
+			return nil, fmt.Errorf("invalid runtime handler %q, expected handler=runtimeType[@address]", item)
+		}
+		runtimeType, address, _ := strings.Cut(backend, "@")
+		handlers = append(handlers, RuntimeHandler{Handler: handler, RuntimeType: runtimeType, Address: address})
+	}
+	return handlers, nil
+}
+
+// newRoutingCRI creates the backends of the handlers, reusing def for
+// handlers configured with the default runtime type and address
+func newRoutingCRI(def CRI, defCapabilities Capabilities, runtimeType, address string, handlers []RuntimeHandler, opts BackendOptions) (*routingCRI, Capabilities, error) {
+	resolver, ok := def.(RuntimeHandlerResolver)
+	if !ok {
+		return nil, Capabilities{}, fmt.Errorf("runtime %s cannot look up the runtime handlers of pods", runtimeType)
+	}
+
+	r := &routingCRI{
+		resolver:   resolver,
+		backends:   map[string]CRI{"": def},
+		containers: make(map[string]CRI),
+		pods:       make(map[string][]string),
+	}
+	capabilities := defCapabilities
+	for _, h := range handlers {
+		if _, dup := r.backends[h.Handler]; dup {
+			return nil, Capabilities{}, fmt.Errorf("runtime handler %s configured twice", h.Handler)
+		}
+		if h.RuntimeType == runtimeType && h.Address == address {
+			r.backends[h.Handler] = def
+			continue
+		}
+		opts.Address = h.Address
+		cri, caps, err := newBackend(h.RuntimeType, opts)
+		if err != nil {
+			return nil, Capabilities{}, fmt.Errorf("backend of runtime handler %s: %w", h.Handler, err)
+		}
+		r.backends[h.Handler] = cri
+		capabilities.Checkpoint = capabilities.Checkpoint || caps.Checkpoint
+		capabilities.Throttle = capabilities.Throttle || caps.Throttle
+		capabilities.Status = capabilities.Status || caps.Status
+	}
+	return r, capabilities, nil
+}
+
+// routingCRI sends the containers of each pod to the backend of the runtime
+// handler of the pod. Containers are routed to the backend which listed them,
+// until their pod is listed again or the freezer forgets it.
+type routingCRI struct {
+	resolver RuntimeHandlerResolver
+	backends map[string]CRI
+
+	mu         sync.Mutex
+	containers map[string]CRI
+	// pods are the containers each pod was last listed with
+	pods map[string][]string
+}
+
+func (r *routingCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	handler, err := r.resolver.RuntimeHandler(ctx, podUID)
+	if err != nil {
+		return nil, err
+	}
+	backend, ok := r.backends[handler]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRuntimeHandler, handler)
+	}
+
+	ids, err := backend.List(ctx, podUID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range r.pods[podUID] {
+		delete(r.containers, id)
+	}
+	for _, id := range ids {
+		r.containers[id] = backend
+	}
+	r.pods[podUID] = append([]string(nil), ids...)
+	return ids, nil
+}
+
+// forgetPod forgets the backends of the containers of the pod, once the
+// freezer no longer needs to call them
+func (r *routingCRI) forgetPod(podUID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range r.pods[podUID] {
+		delete(r.containers, id)
+	}
+	delete(r.pods, podUID)
+}
+
+// PodAnnotations looks the annotations up through the default backend, which
+// runs every sandbox whatever its handler
+func (r *routingCRI) PodAnnotations(ctx context.Context, podUID string) (map[string]string, error) {
+	if a, ok := r.backends[""].(PodAnnotator); ok {
+		return a.PodAnnotations(ctx, podUID)
+	}
+	return nil, nil
+}
+
+func (r *routingCRI) Pause(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	return backend.Pause(ctx, container)
+}
+
+func (r *routingCRI) Resume(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	return backend.Resume(ctx, container)
+}
+
+func (r *routingCRI) Throttle(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	t, ok := backend.(Throttler)
+	if !ok {
+		return errNoThrottle
+	}
+	return t.Throttle(ctx, container)
+}
+
+func (r *routingCRI) Unthrottle(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	t, ok := backend.(Throttler)
+	if !ok {
+		return errNoThrottle
+	}
+	return t.Unthrottle(ctx, container)
+}
+
+func (r *routingCRI) Checkpoint(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	cp, ok := backend.(Checkpointer)
+	if !ok {
+		return common.ErrCheckpointUnsupported
+	}
+	return cp.Checkpoint(ctx, container)
+}
+
+func (r *routingCRI) Restore(ctx context.Context, container string) error {
+	backend, err := r.backend(container)
+	if err != nil {
+		return err
+	}
+	cp, ok := backend.(Checkpointer)
+	if !ok {
+		return common.ErrCheckpointUnsupported
+	}
+	return cp.Restore(ctx, container)
+}
+
+func (r *routingCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	backend, err := r.backend(container)
+	if err != nil {
+		return common.StateUnknown, err
+	}
+	return backend.Status(ctx, container)
+}
+
+// WatchEvents watches the events of every backend which reports them
+func (r *routingCRI) WatchEvents(fn func(common.ContainerEvent)) {
+	for _, backend := range r.backends {
+		if w, ok := backend.(EventWatcher); ok {
+			w.WatchEvents(fn)
+		}
+	}
+}
+
+func (r *routingCRI) backend(container string) (CRI, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	backend, ok := r.containers[container]
+	if !ok {
+		return nil, fmt.Errorf("container %s was not listed, its backend is unknown", container)
+	}
+	return backend, nil
+}
+
+// podForgetter is implemented by runtimes which remember something about the
+// pods they listed
+type podForgetter interface {
+	forgetPod(podUID string)
+}
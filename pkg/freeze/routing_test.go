@@ -0,0 +1,185 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// handlerCRI is a recordingCRI whose pods run with the given runtime handler
+type handlerCRI struct {
+	*recordingCRI
+	handler string
+}
+
+func (c handlerCRI) RuntimeHandler(ctx context.Context, podUID string) (string, error) {
+	return c.handler, nil
+}
+
+// kataCRI is the backend the test-kata runtime type creates
+var kataCRI = newRecordingCRI()
+
+func init() {
+	Register("test-kata", func(opts BackendOptions) (CRI, error) {
+		return kataCRI, nil
+	}, Capabilities{})
+}
+
+func TestParseRuntimeHandlers(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []RuntimeHandler
+		wantErr bool
+	}{{
+		spec: "",
+	}, {
+		spec: "kata=plugin@unix:///var/run/kata.sock, runc=containerd",
+		want: []RuntimeHandler{
+			{Handler: "kata", RuntimeType: "plugin", Address: "unix:///var/run/kata.sock"},
+			{Handler: "runc", RuntimeType: "containerd"},
+		},
+	}, {
+		spec:    "kata",
+		wantErr: true,
+	}, {
+		spec:    "=plugin",
+		wantErr: true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.spec, func(t *testing.T) {
+			got, err := ParseRuntimeHandlers(c.spec)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected handlers %+v but got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRouting(t *testing.T) {
+	handlers := []RuntimeHandler{
+		{Handler: "kata", RuntimeType: "test-kata"},
+		{Handler: "runc", RuntimeType: "test-default"},
+	}
+	tests := []struct {
+		name             string
+		handler          string
+		wantErr          error
+		wantDefaultCalls []string
+		wantKataCalls    []string
+	}{{
+		name:             "default handler",
+		wantDefaultCalls: []string{"pause", "resume"},
+	}, {
+		name:             "handler of the default backend",
+		handler:          "runc",
+		wantDefaultCalls: []string{"pause", "resume"},
+	}, {
+		name:          "other backend",
+		handler:       "kata",
+		wantKataCalls: []string{"pause", "resume"},
+	}, {
+		name:    "unknown handler",
+		handler: "gvisor",
+		wantErr: ErrUnknownRuntimeHandler,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			kataCRI.calls = nil
+			def := handlerCRI{recordingCRI: newRecordingCRI(), handler: c.handler}
+			routing, _, err := newRoutingCRI(def, Capabilities{}, "test-default", "", handlers, BackendOptions{})
+			if err != nil {
+				t.Fatalf("expected the backends to be created but failed: %v", err)
+			}
+			impl := &ContainerRuntimeImpl{cri: routing}
+
+			err = impl.Freeze(context.Background(), "pod")
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if err == nil {
+				if err := impl.Thaw(context.Background(), "pod"); err != nil {
+					t.Fatalf("expected thaw to succeed but failed: %v", err)
+				}
+			}
+
+			if got := def.getCalls(); !reflect.DeepEqual(got, c.wantDefaultCalls) {
+				t.Errorf("expected default backend calls %v but got %v", c.wantDefaultCalls, got)
+			}
+			if got := kataCRI.getCalls(); !reflect.DeepEqual(got, c.wantKataCalls) {
+				t.Errorf("expected kata backend calls %v but got %v", c.wantKataCalls, got)
+			}
+			if len(routing.containers) != 0 {
+				t.Errorf("expected the containers of thawed pods to be forgotten but got %v", routing.containers)
+			}
+		})
+	}
+}
+
+// statusHandlerCRI is a statusCRI whose pods run with the default handler
+type statusHandlerCRI struct {
+	*statusCRI
+}
+
+func (c statusHandlerCRI) RuntimeHandler(ctx context.Context, podUID string) (string, error) {
+	return "", nil
+}
+
+func TestRoutingVerifiesResume(t *testing.T) {
+	def := statusHandlerCRI{statusCRI: newStatusCRI()}
+	routing, _, err := newRoutingCRI(def, Capabilities{Status: true}, "test-default", "", nil, BackendOptions{})
+	if err != nil {
+		t.Fatalf("expected the backends to be created but failed: %v", err)
+	}
+	impl := &ContainerRuntimeImpl{cri: routing}
+	WithFreezeVerification(time.Second, nil)(impl)
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+	// The resume does not take
+	def.set(common.StatePaused)
+	if err := impl.Thaw(context.Background(), "pod"); !errors.Is(err, ErrStateMismatch) {
+		t.Fatalf("expected a state mismatch but got %v", err)
+	}
+
+	def.set(common.StateRunning)
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thawing again to succeed but got %v", err)
+	}
+	if len(routing.containers) != 0 || len(routing.pods) != 0 {
+		t.Errorf("expected the thawed pod to be forgotten but got %v, %v", routing.containers, routing.pods)
+	}
+}
+
+func TestRoutingForgetsUnlistedContainers(t *testing.T) {
+	def := handlerCRI{recordingCRI: newRecordingCRI()}
+	routing, _, err := newRoutingCRI(def, Capabilities{}, "test-default", "", nil, BackendOptions{})
+	if err != nil {
+		t.Fatalf("expected the backends to be created but failed: %v", err)
+	}
+	impl := &ContainerRuntimeImpl{cri: routing}
+
+	// Listing a pod which is not paused, e.g. thawing it, leaves nothing behind
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but got %v", err)
+	}
+	if len(routing.containers) != 0 || len(routing.pods) != 0 {
+		t.Errorf("expected the listed pod to be forgotten but got %v, %v", routing.containers, routing.pods)
+	}
+}
+
+func TestRoutingNeedsResolver(t *testing.T) {
+	_, err := NewCRIProvider("test-recording", WithRuntimeHandlers(RuntimeHandler{Handler: "kata", RuntimeType: "test-kata"}))
+	if err == nil {
+		t.Error("expected routing through a backend which cannot look up runtime handlers to fail")
+	}
+}
@@ -0,0 +1,195 @@
+package freeze
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/utils/clock"
+)
+
+// podState is what the freezer remembers about a pod between requests
+type podState struct {
+	// pause is the pending delayed pause, if any
+	pause clock.Timer
+	// pauseGen identifies the pending pause, so a timer which fires after
+	// being cancelled can tell it is stale
+	pauseGen uint64
+	// thawedAt is when the pod was last thawed
+	thawedAt time.Time
+
+	// latest identifies the most recent request for the pod, a request
+	// waiting for another to finish gives up if it is no longer the latest
+	latest uint64
+	// busy is closed when the operation running on the pod finishes, it is
+	// nil if no operation is running
+	busy chan struct{}
+	// waiters is the number of requests waiting for busy to be closed
+	waiters int
+	// superseded is set while the running operation is a freeze, and is
+	// closed when a resume for the pod arrives
+	superseded chan struct{}
+
+	// tracked is set once the freezer has started pausing the pod, from then
+	// on paused holds the containers it has paused and not yet resumed
+	tracked bool
+	paused  []string
+	// stopping is the container being paused or throttled, if any, until it
+	// is recorded in paused
+	stopping string
+	// mode is how the containers in paused were stopped, ModeFreeze or
+	// ModeThrottle, it is empty while no container is paused
+	mode string
+	// frozenAt is when the first container of the pod was paused, it is
+	// zero while no container is paused
+	frozenAt time.Time
+
+	// checkpoint is the pending checkpoint of the frozen pod, if any
+	checkpoint    clock.Timer
+	checkpointGen uint64
+	// checkpointed holds the paused containers which were checkpointed to
+	// disk, they are restored rather than resumed. They are kept in the state
+	// directory as well.
+	checkpointed []string
+	// noCheckpoint is set once the runtime failed to checkpoint the pod
+	// because it does not support it
+	noCheckpoint bool
+
+	// reclaim is the pending memory reclaim of the frozen pod, if any
+	reclaim    clock.Timer
+	reclaimGen uint64
+	// reclaimedBytes is how much memory was reclaimed since the pod was frozen
+	reclaimedBytes int64
+}
+
+func (c *ContainerRuntimeImpl) getClock() clock.WithDelayedExecution {
+	if c.clock == nil {
+		return clock.RealClock{}
+	}
+	return c.clock
+}
+
+func (c *ContainerRuntimeImpl) getLogger() *zap.SugaredLogger {
+	if c.logger == nil {
+		return zap.NewNop().Sugar()
+	}
+	return c.logger
+}
+
+// podStateLocked returns the state of the pod, creating it if needed. c.mu must be held.
+func (c *ContainerRuntimeImpl) podStateLocked(podName string) *podState {
+	if c.pods == nil {
+		c.pods = make(map[string]*podState)
+	}
+	st, ok := c.pods[podName]
+	if !ok {
+		st = &podState{}
+		c.pods[podName] = st
+	}
+	return st
+}
+
+// forgetLocked removes the state of the pod if it has nothing worth keeping. c.mu must be held.
+func (c *ContainerRuntimeImpl) forgetLocked(podName string, st *podState) {
+	if st.pause != nil || st.checkpoint != nil || st.reclaim != nil || st.busy != nil || st.waiters > 0 || len(st.paused) > 0 {
+		return
+	}
+	if c.minRunDuration > 0 && !st.thawedAt.IsZero() {
+		return
+	}
+	delete(c.pods, podName)
+	if f, ok := c.cri.(podForgetter); ok {
+		f.forgetPod(podName)
+	}
+}
+
+// nextGenLocked returns a new generation number. c.mu must be held.
+func (c *ContainerRuntimeImpl) nextGenLocked() uint64 {
+	c.gen++
+	return c.gen
+}
+
+// serialize runs op on the pod once no other operation is running on it, so
+// that a pause and a resume never interleave container by container. The
+// latest request wins: a resume marks a running pause as superseded, and a
+// request which is superseded while waiting returns without running.
+func (c *ContainerRuntimeImpl) serialize(ctx context.Context, podName string, isFreeze bool, op func(*podState) error) error {
+	return c.serializeAs(ctx, podName, 0, isFreeze, op)
+}
+
+// serializeAs is serialize for the request queued as gen by QueueFreeze, or a
+// new request if gen is zero. A queued request which is no longer the latest
+// returns without running.
+func (c *ContainerRuntimeImpl) serializeAs(ctx context.Context, podName string, gen uint64, isFreeze bool, op func(*podState) error) error {
+	c.mu.Lock()
+	st := c.podStateLocked(podName)
+	if gen == 0 {
+		gen = c.nextGenLocked()
+		st.latest = gen
+	} else {
+		// The request is no longer queued, it waits like any other
+		st.waiters--
+		if st.latest != gen {
+			c.forgetLocked(podName, st)
+			c.mu.Unlock()
+			recordSuppressed(reasonSuperseded)
+			return nil
+		}
+	}
+
+	for st.busy != nil {
+		if !isFreeze && st.superseded != nil {
+			supersedeLocked(st)
+		}
+
+		busy := st.busy
+		st.waiters++
+		c.mu.Unlock()
+
+		var err error
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		c.mu.Lock()
+		st.waiters--
+		if err != nil {
+			c.forgetLocked(podName, st)
+			c.mu.Unlock()
+			return err
+		}
+		if st.latest != gen {
+			c.forgetLocked(podName, st)
+			c.mu.Unlock()
+			recordSuppressed(reasonSuperseded)
+			return nil
+		}
+	}
+
+	st.busy = make(chan struct{})
+	if isFreeze {
+		st.superseded = make(chan struct{})
+	}
+	c.mu.Unlock()
+
+	err := op(st)
+
+	c.mu.Lock()
+	close(st.busy)
+	st.busy = nil
+	st.superseded = nil
+	c.forgetLocked(podName, st)
+	c.mu.Unlock()
+	return err
+}
+
+// supersedeLocked tells the running freeze of the pod to stop. c.mu must be held.
+func supersedeLocked(st *podState) {
+	select {
+	case <-st.superseded:
+	default:
+		close(st.superseded)
+	}
+}
@@ -0,0 +1,137 @@
+package freeze
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingCRI is a CRI for a pod with two containers whose first Pause blocks until released
+type blockingCRI struct {
+	recordingCRI
+	pausing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingCRI() *blockingCRI {
+	return &blockingCRI{
+		pausing: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blockingCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	return []string{"ctr1", "ctr2"}, nil
+}
+
+func (b *blockingCRI) Pause(ctx context.Context, container string) error {
+	b.once.Do(func() {
+		close(b.pausing)
+		<-b.release
+	})
+	b.record("pause " + container)
+	return nil
+}
+
+func (b *blockingCRI) Resume(ctx context.Context, container string) error {
+	b.record("resume " + container)
+	return nil
+}
+
+// waitForWaiters waits until n requests for the pod are waiting for the running one
+func waitForWaiters(t *testing.T, c *ContainerRuntimeImpl, podName string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		st := c.pods[podName]
+		waiters := 0
+		if st != nil {
+			waiters = st.waiters
+		}
+		c.mu.Unlock()
+		if waiters >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d waiting requests", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestResumeSupersedesRunningPause(t *testing.T) {
+	fake := newBlockingCRI()
+	c := &ContainerRuntimeImpl{cri: fake}
+
+	freezeErr := make(chan error)
+	go func() { freezeErr <- c.Freeze(context.Background(), "pod") }()
+	<-fake.pausing
+
+	thawErr := make(chan error)
+	go func() { thawErr <- c.Thaw(context.Background(), "pod") }()
+	waitForWaiters(t, c, "pod", 1)
+	close(fake.release)
+
+	if err := <-freezeErr; err != nil {
+		t.Errorf("expected superseded freeze to succeed but failed: %v", err)
+	}
+	if err := <-thawErr; err != nil {
+		t.Errorf("expected thaw to succeed but failed: %v", err)
+	}
+
+	// The pause stops after the container in flight, and only that one is resumed
+	want := []string{"pause ctr1", "resume ctr1"}
+	if got := fake.getCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+	if len(c.pods) != 0 {
+		t.Errorf("expected no pod state to be kept but got %d entries", len(c.pods))
+	}
+}
+
+func TestLatestIntentWins(t *testing.T) {
+	fake := newBlockingCRI()
+	c := &ContainerRuntimeImpl{cri: fake}
+	before := suppressedCount(t, reasonSuperseded)
+
+	freezeErr := make(chan error)
+	go func() { freezeErr <- c.Freeze(context.Background(), "pod") }()
+	<-fake.pausing
+
+	// A resume and then another pause arrive while the first pause runs, so
+	// the resume is superseded and the pod ends up paused.
+	thawErr := make(chan error)
+	go func() { thawErr <- c.Thaw(context.Background(), "pod") }()
+	waitForWaiters(t, c, "pod", 1)
+	secondFreezeErr := make(chan error)
+	go func() { secondFreezeErr <- c.Freeze(context.Background(), "pod") }()
+	waitForWaiters(t, c, "pod", 2)
+	close(fake.release)
+
+	for _, ch := range []chan error{freezeErr, thawErr, secondFreezeErr} {
+		if err := <-ch; err != nil {
+			t.Errorf("expected request to succeed but failed: %v", err)
+		}
+	}
+
+	want := []string{"pause ctr1", "pause ctr2"}
+	if got := fake.getCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+	// Both the first pause and the resume were superseded
+	if got := suppressedCount(t, reasonSuperseded) - before; got != 2 {
+		t.Errorf("expected 2 superseded requests to be recorded but got %d", got)
+	}
+
+	if err := c.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thaw to succeed but failed: %v", err)
+	}
+	want = append(want, "resume ctr1", "resume ctr2")
+	if got := fake.getCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+}
@@ -0,0 +1,57 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// ErrStateMismatch is returned when a container is not in the state a pause
+// or resume the runtime reported as successful should have left it in
+var ErrStateMismatch = errors.New("container state does not match")
+
+// StateMismatchError reports a container which is not in the state the
+// freezer expects it to be in
+type StateMismatchError struct {
+	Container string
+	Want      common.ContainerState
+	Got       common.ContainerState
+}
+
+func (e *StateMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s is %s, expected %s", ErrStateMismatch, e.Container, e.Got, e.Want)
+}
+
+func (e *StateMismatchError) Is(target error) bool {
+	return target == ErrStateMismatch
+}
+
+// status reports the state of a container, ok is false if the runtime cannot
+// report it
+func (c *ContainerRuntimeImpl) status(ctx context.Context, ctr string) (state common.ContainerState, ok bool, err error) {
+	state, err = c.cri.Status(ctx, ctr)
+	if errors.Is(err, common.ErrStatusUnsupported) {
+		return common.StateUnknown, false, nil
+	}
+	return state, true, err
+}
+
+// verifyResumed checks that a resumed container is running again. A container
+// which exited meanwhile is fine.
+func (c *ContainerRuntimeImpl) verifyResumed(ctx context.Context, ctr string) error {
+	if c.verifyTimeout <= 0 {
+		return nil
+	}
+	state, ok, err := c.status(ctx, ctr)
+	if err != nil {
+		c.getLogger().Warnf("checking %s is running: %v", ctr, err)
+		return nil
+	}
+	if !ok || state == common.StateRunning || state == common.StateStopped {
+		return nil
+	}
+	recordStateMismatch(mismatchNotResumed)
+	return &StateMismatchError{Container: ctr, Want: common.StateRunning, Got: state}
+}
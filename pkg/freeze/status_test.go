@@ -0,0 +1,148 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// statusCRI is a recordingCRI which tracks the state of its container, unless
+// the state is pinned by setting it
+type statusCRI struct {
+	*recordingCRI
+	mu     sync.Mutex
+	state  common.ContainerState
+	pinned bool
+}
+
+func newStatusCRI() *statusCRI {
+	return &statusCRI{recordingCRI: newRecordingCRI(), state: common.StateRunning}
+}
+
+func (s *statusCRI) Pause(ctx context.Context, container string) error {
+	s.transition(common.StatePaused)
+	return s.recordingCRI.Pause(ctx, container)
+}
+
+func (s *statusCRI) Resume(ctx context.Context, container string) error {
+	s.transition(common.StateRunning)
+	return s.recordingCRI.Resume(ctx, container)
+}
+
+func (s *statusCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, nil
+}
+
+func (s *statusCRI) transition(state common.ContainerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pinned {
+		s.state = state
+	}
+}
+
+func (s *statusCRI) set(state common.ContainerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.pinned = true
+}
+
+func TestThawVerifiesResume(t *testing.T) {
+	fake := newStatusCRI()
+	impl := &ContainerRuntimeImpl{cri: fake}
+	WithFreezeVerification(time.Second, nil)(impl)
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+
+	fake.set(common.StatePaused)
+	err := impl.Thaw(context.Background(), "pod")
+	var mismatch *StateMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrStateMismatch) || mismatch.Got != common.StatePaused {
+		t.Fatalf("expected a state mismatch but got %v", err)
+	}
+	if frozen := impl.Frozen(); len(frozen) != 1 {
+		t.Errorf("expected the container to stay recorded as paused but got %+v", frozen)
+	}
+
+	fake.set(common.StateRunning)
+	if err := impl.Thaw(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected thawing again to succeed but got %v", err)
+	}
+	if got, want := fake.getCalls(), []string{"pause", "resume", "resume"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected calls %v but got %v", want, got)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name   string
+		frozen bool
+		state  common.ContainerState
+		want   []StateMismatchError
+	}{{
+		name:   "frozen and paused",
+		frozen: true,
+		state:  common.StatePaused,
+	}, {
+		name:   "frozen but resumed behind the freezer's back",
+		frozen: true,
+		state:  common.StateRunning,
+		want:   []StateMismatchError{{Container: "ctr", Want: common.StatePaused, Got: common.StateRunning}},
+	}, {
+		name:   "frozen container exited",
+		frozen: true,
+		state:  common.StateStopped,
+	}, {
+		name:  "thawed and running",
+		state: common.StateRunning,
+	}, {
+		name:  "thawed but paused behind the freezer's back",
+		state: common.StatePaused,
+		want:  []StateMismatchError{{Container: "ctr", Want: common.StateRunning, Got: common.StatePaused}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newStatusCRI()
+			// Keep thawed pods around so that they can be audited
+			impl := &ContainerRuntimeImpl{cri: fake, minRunDuration: time.Hour}
+			if err := impl.freeze(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected freeze to succeed but got %v", err)
+			}
+			if !test.frozen {
+				if err := impl.Thaw(context.Background(), "pod"); err != nil {
+					t.Fatalf("expected thaw to succeed but got %v", err)
+				}
+			}
+
+			fake.set(test.state)
+			got := impl.Audit(context.Background())
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected mismatches %+v but got %+v", test.want, got)
+			}
+			if errs := impl.RecentErrors(); len(errs) != len(test.want) || (len(errs) > 0 && errs[0].Action != ActionAudit) {
+				t.Errorf("expected mismatches to be recorded but got %+v", errs)
+			}
+		})
+	}
+}
+
+func TestAuditSkippedWithoutStatus(t *testing.T) {
+	impl := &ContainerRuntimeImpl{cri: newRecordingCRI()}
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+	if got := impl.Audit(context.Background()); len(got) != 0 {
+		t.Errorf("expected no mismatches but got %+v", got)
+	}
+}
@@ -0,0 +1,119 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// ErrStuckFreeze is returned when a paused container did not reach the paused
+// state in time, usually because one of its tasks is in uninterruptible sleep
+// and keeps the kernel freezer in FREEZING
+var ErrStuckFreeze = errors.New("freeze stuck")
+
+// verifyInterval is how often the state of a container is polled while
+// waiting for it to be paused
+const verifyInterval = 50 * time.Millisecond
+
+// StuckFreezeError reports a container which was not paused in time. The pod
+// was thawed again before it was returned.
+type StuckFreezeError struct {
+	Container string
+	// State is the last state the runtime reported for the container
+	State common.ContainerState
+	// PIDs are the processes holding up the freeze, if they could be found
+	PIDs []int
+	// Err is set when thawing the pod again failed
+	Err error
+}
+
+func (e *StuckFreezeError) Error() string {
+	msg := fmt.Sprintf("%s: %s still %s", ErrStuckFreeze, e.Container, e.State)
+	if len(e.PIDs) > 0 {
+		msg += fmt.Sprintf(", blocked by pids %v", e.PIDs)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(", %v", e.Err)
+	}
+	return msg
+}
+
+func (e *StuckFreezeError) Is(target error) bool {
+	return target == ErrStuckFreeze
+}
+
+func (e *StuckFreezeError) Unwrap() error {
+	return e.Err
+}
+
+// StuckProcessFinder finds the processes of a container which keep it from
+// being frozen
+type StuckProcessFinder interface {
+	StuckProcesses(ctx context.Context, container string) ([]int, error)
+}
+
+// WithFreezeVerification checks that paused containers reach the paused state
+// within timeout, and thaws the pod if one does not, and that resumed
+// containers are running again. finder may be nil, it is used to report the
+// processes holding up the freeze. Verification needs a runtime which can
+// report the state of containers, and is skipped otherwise.
+func WithFreezeVerification(timeout time.Duration, finder StuckProcessFinder) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.verifyTimeout = timeout
+		c.stuckFinder = finder
+	}
+}
+
+// verifyPaused waits for a paused container to reach the paused state, and
+// returns the last state seen if it did not within the verification timeout
+func (c *ContainerRuntimeImpl) verifyPaused(ctx context.Context, ctr string) (common.ContainerState, bool) {
+	if c.verifyTimeout <= 0 {
+		return common.StatePaused, true
+	}
+
+	clk := c.getClock()
+	deadline := clk.Now().Add(c.verifyTimeout)
+	state := common.StateUnknown
+	for {
+		got, reported, err := c.status(ctx, ctr)
+		switch {
+		case !reported:
+			return common.StatePaused, true
+		case err != nil:
+			c.getLogger().Warnf("checking %s is paused: %v", ctr, err)
+		case got == common.StatePaused, got == common.StateStopped:
+			return got, true
+		default:
+			state = got
+		}
+		if !clk.Now().Before(deadline) {
+			return state, false
+		}
+		select {
+		case <-ctx.Done():
+			// The pause went through, whether it completes is up to the kernel
+			return state, true
+		case <-clk.After(verifyInterval):
+		}
+	}
+}
+
+// stuck thaws a pod whose container ctr did not reach the paused state
+func (c *ContainerRuntimeImpl) stuck(podName string, st *podState, mode, ctr string, state common.ContainerState) error {
+	recordStuckFreeze()
+	stuckErr := &StuckFreezeError{Container: ctr, State: state}
+	if c.stuckFinder != nil {
+		pids, err := c.stuckFinder.StuckProcesses(context.Background(), ctr)
+		if err != nil {
+			c.getLogger().Warnf("finding the processes holding up %s: %v", ctr, err)
+		}
+		stuckErr.PIDs = pids
+	}
+	c.getLogger().Errorw("freeze stuck, thawing pod", "pod", podName, "container", ctr, "state", state, "pids", stuckErr.PIDs)
+
+	stuckErr.Err = c.rollback(podName, st, mode, ctr)
+	return stuckErr
+}
@@ -0,0 +1,103 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// stuckCRI reports the state of its containers, stuck is never paused
+type stuckCRI struct {
+	hangingCRI
+	stuck string
+	state common.ContainerState
+}
+
+func (s *stuckCRI) List(ctx context.Context, podUID string) ([]string, error) {
+	return []string{"ctr", "stuck"}, nil
+}
+
+func (s *stuckCRI) Pause(ctx context.Context, container string) error {
+	s.record("pause " + container)
+	return nil
+}
+
+func (s *stuckCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	if container == s.stuck {
+		return s.state, nil
+	}
+	return common.StatePaused, nil
+}
+
+type stuckFinder []int
+
+func (f stuckFinder) StuckProcesses(ctx context.Context, container string) ([]int, error) {
+	return f, nil
+}
+
+func TestFreezeVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		stuck     string
+		state     common.ContainerState
+		wantStuck bool
+		wantCalls []string
+	}{{
+		name:      "paused",
+		wantCalls: []string{"pause ctr", "pause stuck"},
+	}, {
+		name:      "exited while pausing",
+		stuck:     "stuck",
+		state:     common.StateStopped,
+		wantCalls: []string{"pause ctr", "pause stuck"},
+	}, {
+		name:      "stuck freezing",
+		stuck:     "stuck",
+		state:     common.StatePausing,
+		wantStuck: true,
+		wantCalls: []string{"pause ctr", "pause stuck", "resume stuck", "resume ctr"},
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &stuckCRI{stuck: c.stuck, state: c.state}
+			impl := &ContainerRuntimeImpl{cri: fake}
+			WithFreezeVerification(20*time.Millisecond, stuckFinder{42})(impl)
+
+			err := impl.Freeze(context.Background(), "pod")
+			if got := errors.Is(err, ErrStuckFreeze); got != c.wantStuck {
+				t.Fatalf("expected stuck %v but got %v", c.wantStuck, err)
+			}
+			if c.wantStuck {
+				var stuckErr *StuckFreezeError
+				if !errors.As(err, &stuckErr) || stuckErr.Container != "stuck" || stuckErr.State != common.StatePausing ||
+					!reflect.DeepEqual(stuckErr.PIDs, []int{42}) || stuckErr.Err != nil {
+					t.Errorf("expected the stuck container and its pids but got %#v", err)
+				}
+				if frozen := impl.Frozen(); len(frozen) != 0 {
+					t.Errorf("expected the pod to be thawed but got %+v", frozen)
+				}
+			}
+			if !reflect.DeepEqual(fake.calls, c.wantCalls) {
+				t.Errorf("expected calls %v but got %v", c.wantCalls, fake.calls)
+			}
+		})
+	}
+}
+
+func TestFreezeVerificationSkippedWithoutStatus(t *testing.T) {
+	fake := newRecordingCRI()
+	impl := &ContainerRuntimeImpl{cri: fake}
+	WithFreezeVerification(time.Millisecond, nil)(impl)
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+	if got := fake.getCalls(); !reflect.DeepEqual(got, []string{"pause"}) {
+		t.Errorf("expected a single pause but got %v", got)
+	}
+}
@@ -8,11 +8,24 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	containersv1 "github.com/containerd/containerd/api/services/containers/v1"
+	eventsv1 "github.com/containerd/containerd/api/services/events/v1"
+	leasesv1 "github.com/containerd/containerd/api/services/leases/v1"
+	snapshotsv1 "github.com/containerd/containerd/api/services/snapshots/v1"
 	ctrdv1 "github.com/containerd/containerd/api/services/tasks/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/api/types/task"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/runtime/v2/runc/options"
+	"github.com/containerd/typeurl"
 	types1 "github.com/gogo/protobuf/types"
+	digest "github.com/opencontainers/go-digest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 )
 
@@ -22,15 +35,22 @@ type MockCtr struct {
 	Id    string
 	Name  string
 	State string
+	// Info is the verbose info the runtime reports for the container
+	Info string
 }
 
 type MockPod struct {
-	Id   string
-	Ctrs []MockCtr
+	Id          string
+	Ctrs        []MockCtr
+	Annotations map[string]string
+	// RuntimeHandler is the RuntimeClass handler the sandbox runs with
+	RuntimeHandler string
 }
 
 type CRIServer struct {
 	Pod []MockPod
+	// Updates records the resource updates made to containers
+	Updates []*v1alpha2.UpdateContainerResourcesRequest
 }
 
 func NewCriRuntimeServer() *CRIServer {
@@ -87,7 +107,16 @@ func (c *CRIServer) RemovePodSandbox(ctx context.Context,
 
 func (c *CRIServer) PodSandboxStatus(ctx context.Context,
 	req *v1alpha2.PodSandboxStatusRequest) (*v1alpha2.PodSandboxStatusResponse, error) {
-	return nil, nil
+	for _, v := range c.Pod {
+		if v.Id == req.PodSandboxId {
+			return &v1alpha2.PodSandboxStatusResponse{Status: &v1alpha2.PodSandboxStatus{
+				Id:             v.Id,
+				State:          v1alpha2.PodSandboxState_SANDBOX_READY,
+				RuntimeHandler: v.RuntimeHandler,
+			}}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "pod sandbox %s not found", req.PodSandboxId)
 }
 
 func (c *CRIServer) ListPodSandbox(ctx context.Context,
@@ -99,7 +128,8 @@ func (c *CRIServer) ListPodSandbox(ctx context.Context,
 	for _, v := range c.Pod {
 		if v.Id == req.Filter.LabelSelector["io.kubernetes.pod.uid"] {
 			item := &v1alpha2.PodSandbox{
-				Id: v.Id,
+				Id:          v.Id,
+				Annotations: v.Annotations,
 			}
 			data.Items = append(data.Items, item)
 		}
@@ -153,12 +183,35 @@ func (c *CRIServer) ListContainers(ctx context.Context,
 
 func (c *CRIServer) ContainerStatus(ctx context.Context,
 	req *v1alpha2.ContainerStatusRequest) (*v1alpha2.ContainerStatusResponse, error) {
-	return nil, nil
+	for _, pod := range c.Pod {
+		for _, ctr := range pod.Ctrs {
+			if ctr.Id == req.ContainerId {
+				resp := &v1alpha2.ContainerStatusResponse{
+					Status: &v1alpha2.ContainerStatus{Id: ctr.Id, Metadata: &v1alpha2.ContainerMetadata{Name: ctr.Name}, State: criContainerState[ctr.State]},
+				}
+				if req.Verbose && ctr.Info != "" {
+					resp.Info = map[string]string{"info": ctr.Info}
+				}
+				return resp, nil
+			}
+		}
+	}
+	return nil, status.Error(codes.NotFound, "can't found ctr")
+}
+
+// criContainerState maps the states of mock containers to their CRI state,
+// paused containers are reported as unknown like CRI-O does
+var criContainerState = map[string]v1alpha2.ContainerState{
+	"created": v1alpha2.ContainerState_CONTAINER_CREATED,
+	"running": v1alpha2.ContainerState_CONTAINER_RUNNING,
+	"paused":  v1alpha2.ContainerState_CONTAINER_UNKNOWN,
+	"exited":  v1alpha2.ContainerState_CONTAINER_EXITED,
 }
 
 func (c *CRIServer) UpdateContainerResources(ctx context.Context,
 	req *v1alpha2.UpdateContainerResourcesRequest) (*v1alpha2.UpdateContainerResourcesResponse, error) {
-	return nil, nil
+	c.Updates = append(c.Updates, req)
+	return &v1alpha2.UpdateContainerResourcesResponse{}, nil
 }
 
 func (c *CRIServer) ReopenContainerLog(ctx context.Context,
@@ -236,21 +289,132 @@ func RunCriServer(c *CRIServer, socketPath string) {
 
 type CtrdServer struct {
 	Ctrs []MockCtr
+	// CheckpointUnsupported makes Checkpoint fail as if CRIU was unavailable
+	CheckpointUnsupported bool
+	// Leases holds the IDs of the leases which have not been deleted
+	Leases []string
+
+	events ctrdEvents
+}
+
+// ctrdEvents streams the events published with CtrdServer.PublishEvent to
+// subscribers, ignoring their filters
+type ctrdEvents struct {
+	eventsv1.UnimplementedEventsServer
+
+	mu          sync.Mutex
+	subscribers []chan *eventsv1.Envelope
+}
+
+func (e *ctrdEvents) Subscribe(req *eventsv1.SubscribeRequest, srv eventsv1.Events_SubscribeServer) error {
+	ch := make(chan *eventsv1.Envelope, 16)
+	e.mu.Lock()
+	e.subscribers = append(e.subscribers, ch)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for i, sub := range e.subscribers {
+			if sub == ch {
+				e.subscribers = append(e.subscribers[:i], e.subscribers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	for {
+		select {
+		case envelope := <-ch:
+			if err := srv.Send(envelope); err != nil {
+				return err
+			}
+		case <-srv.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Subscribers returns how many clients are subscribed to events
+func (c *CtrdServer) Subscribers() int {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	return len(c.events.subscribers)
+}
+
+// PublishEvent sends an event of the k8s.io namespace to the subscribers
+func (c *CtrdServer) PublishEvent(topic string, event interface{}) error {
+	payload, err := typeurl.MarshalAny(event)
+	if err != nil {
+		return err
+	}
+	envelope := &eventsv1.Envelope{Timestamp: time.Now(), Namespace: "k8s.io", Topic: topic, Event: payload}
+
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	for _, sub := range c.events.subscribers {
+		sub <- envelope
+	}
+	return nil
+}
+
+// CtrState returns the state of the container, or "" if there is none
+func (c *CtrdServer) CtrState(id string) string {
+	for _, v := range c.Ctrs {
+		if v.Id == id {
+			return v.State
+		}
+	}
+	return ""
+}
+
+// transition moves the container from the state from to the state to
+func (c *CtrdServer) transition(id, from, to string) error {
+	for i, v := range c.Ctrs {
+		if v.Id == id {
+			if v.State != from {
+				return fmt.Errorf("not in %s state", from)
+			}
+			c.Ctrs[i].State = to
+			return nil
+		}
+	}
+	return fmt.Errorf("can't found ctr")
+}
+
+// ctrdTaskStatus maps the states of mock containers to the status of their task
+var ctrdTaskStatus = map[string]task.Status{
+	"running":      task.StatusRunning,
+	"paused":       task.StatusPaused,
+	"pausing":      task.StatusPausing,
+	"checkpointed": task.StatusStopped,
+	"created":      task.StatusCreated,
 }
 
 func (c *CtrdServer) Create(ctx context.Context,
 	req *ctrdv1.CreateTaskRequest) (*ctrdv1.CreateTaskResponse, error) {
-	return nil, nil
+	if req.Checkpoint == nil || len(req.Rootfs) == 0 {
+		return nil, fmt.Errorf("only restoring from a checkpoint is supported")
+	}
+	if err := c.transition(req.ContainerID, "deleted", "created"); err != nil {
+		return nil, err
+	}
+	return &ctrdv1.CreateTaskResponse{ContainerID: req.ContainerID}, nil
 }
 
 func (c *CtrdServer) Start(ctx context.Context,
 	req *ctrdv1.StartRequest) (*ctrdv1.StartResponse, error) {
-	return nil, nil
+	if err := c.transition(req.ContainerID, "created", "running"); err != nil {
+		return nil, err
+	}
+	return &ctrdv1.StartResponse{}, nil
 }
 
 func (c *CtrdServer) Delete(ctx context.Context,
 	req *ctrdv1.DeleteTaskRequest) (*ctrdv1.DeleteResponse, error) {
-	return nil, nil
+	if err := c.transition(req.ContainerID, "checkpointed", "deleted"); err != nil {
+		return nil, err
+	}
+	return &ctrdv1.DeleteResponse{}, nil
 }
 
 func (c *CtrdServer) DeleteProcess(ctx context.Context,
@@ -260,7 +424,16 @@ func (c *CtrdServer) DeleteProcess(ctx context.Context,
 
 func (c *CtrdServer) Get(ctx context.Context,
 	req *ctrdv1.GetRequest) (*ctrdv1.GetResponse, error) {
-	return nil, nil
+	state := c.CtrState(req.ContainerID)
+	if state == "" {
+		return nil, status.Error(codes.NotFound, "can't found ctr")
+	}
+	return &ctrdv1.GetResponse{Process: &task.Process{
+		ContainerID: req.ContainerID,
+		Stdout:      "/run/" + req.ContainerID + "/stdout",
+		Stderr:      "/run/" + req.ContainerID + "/stderr",
+		Status:      ctrdTaskStatus[state],
+	}}, nil
 }
 
 func (c *CtrdServer) List(ctx context.Context,
@@ -306,9 +479,10 @@ func (c *CtrdServer) Pause(ctx context.Context,
 
 func (c *CtrdServer) Resume(ctx context.Context,
 	req *ctrdv1.ResumeTaskRequest) (*types1.Empty, error) {
-	for _, v := range c.Ctrs {
+	for i, v := range c.Ctrs {
 		if req.ContainerID == v.Id {
 			if v.State == "paused" {
+				c.Ctrs[i].State = "running"
 				data := &types1.Empty{}
 				return data, nil
 			} else {
@@ -327,7 +501,27 @@ func (c *CtrdServer) ListPids(ctx context.Context,
 
 func (c *CtrdServer) Checkpoint(ctx context.Context,
 	req *ctrdv1.CheckpointTaskRequest) (*ctrdv1.CheckpointTaskResponse, error) {
-	return nil, nil
+	if c.CheckpointUnsupported {
+		return nil, status.Error(codes.Unimplemented, "criu not found")
+	}
+	// Only a checkpoint exiting the task stops the container
+	var opts options.CheckpointOptions
+	if req.Options != nil {
+		if err := typeurl.UnmarshalTo(req.Options, &opts); err != nil {
+			return nil, err
+		}
+	}
+	to := "paused"
+	if opts.Exit {
+		to = "checkpointed"
+	}
+	if err := c.transition(req.ContainerID, "paused", to); err != nil {
+		return nil, err
+	}
+	return &ctrdv1.CheckpointTaskResponse{Descriptors: []*types.Descriptor{{
+		MediaType: images.MediaTypeContainerd1Checkpoint,
+		Digest:    digest.FromString(req.ContainerID),
+	}}}, nil
 }
 
 func (c *CtrdServer) Update(ctx context.Context,
@@ -357,12 +551,85 @@ func RunCtrdServer(c *CtrdServer, socketPath string) {
 
 	s := grpc.NewServer()
 	ctrdv1.RegisterTasksServer(s, c)
+	containersv1.RegisterContainersServer(s, &ctrdContainers{})
+	snapshotsv1.RegisterSnapshotsServer(s, &ctrdSnapshots{})
+	leasesv1.RegisterLeasesServer(s, ctrdLeases{c})
+	eventsv1.RegisterEventsServer(s, &c.events)
 
 	if err := s.Serve(ctrdLis); err != nil {
 		panic(fmt.Sprintf("failed to serve: %v", err))
 	}
 }
 
+// ctrdContainers serves the container records needed to restore checkpoints
+type ctrdContainers struct {
+	containersv1.UnimplementedContainersServer
+}
+
+func (ctrdContainers) Get(ctx context.Context,
+	req *containersv1.GetContainerRequest) (*containersv1.GetContainerResponse, error) {
+	return &containersv1.GetContainerResponse{Container: containersv1.Container{
+		ID:          req.ID,
+		Snapshotter: "overlayfs",
+		SnapshotKey: req.ID,
+	}}, nil
+}
+
+// ctrdSnapshots serves the rootfs mounts needed to restore checkpoints
+type ctrdSnapshots struct {
+	snapshotsv1.UnimplementedSnapshotsServer
+}
+
+func (ctrdSnapshots) Mounts(ctx context.Context,
+	req *snapshotsv1.MountsRequest) (*snapshotsv1.MountsResponse, error) {
+	return &snapshotsv1.MountsResponse{Mounts: []*types.Mount{{
+		Type:   "overlay",
+		Source: "overlay",
+	}}}, nil
+}
+
+// ctrdLeases records the leases protecting checkpoints
+type ctrdLeases struct {
+	c *CtrdServer
+}
+
+func (l ctrdLeases) Create(ctx context.Context,
+	req *leasesv1.CreateRequest) (*leasesv1.CreateResponse, error) {
+	l.c.Leases = append(l.c.Leases, req.ID)
+	return &leasesv1.CreateResponse{Lease: &leasesv1.Lease{ID: req.ID}}, nil
+}
+
+func (l ctrdLeases) Delete(ctx context.Context,
+	req *leasesv1.DeleteRequest) (*types1.Empty, error) {
+	for i, id := range l.c.Leases {
+		if id == req.ID {
+			l.c.Leases = append(l.c.Leases[:i], l.c.Leases[i+1:]...)
+			break
+		}
+	}
+	return &types1.Empty{}, nil
+}
+
+func (ctrdLeases) List(ctx context.Context,
+	req *leasesv1.ListRequest) (*leasesv1.ListResponse, error) {
+	return &leasesv1.ListResponse{}, nil
+}
+
+func (ctrdLeases) AddResource(ctx context.Context,
+	req *leasesv1.AddResourceRequest) (*types1.Empty, error) {
+	return &types1.Empty{}, nil
+}
+
+func (ctrdLeases) DeleteResource(ctx context.Context,
+	req *leasesv1.DeleteResourceRequest) (*types1.Empty, error) {
+	return &types1.Empty{}, nil
+}
+
+func (ctrdLeases) ListResources(ctx context.Context,
+	req *leasesv1.ListResourcesRequest) (*leasesv1.ListResourcesResponse, error) {
+	return &leasesv1.ListResourcesResponse{}, nil
+}
+
 type CrioServer struct {
 	Ctrs []MockCtr
 }
@@ -0,0 +1,69 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	// ModeFreeze pauses the containers of a pod
+	ModeFreeze = "freeze"
+	// ModeThrottle keeps the containers of a pod running with a minimal
+	// amount of CPU, for workloads which cannot tolerate being stopped
+	ModeThrottle = "throttle"
+
+	// ModeAnnotation selects the mode for a pod, overriding the default
+	ModeAnnotation = "container-freezer.knative.dev/mode"
+)
+
+var errNoThrottle = errors.New("runtime cannot throttle containers")
+
+// Throttler is implemented by runtimes which can throttle the CPU of a
+// container instead of pausing it
+type Throttler interface {
+	Throttle(ctx context.Context, container string) error
+	Unthrottle(ctx context.Context, container string) error
+}
+
+// PodAnnotator is implemented by runtimes which can look up the annotations
+// of a pod
+type PodAnnotator interface {
+	PodAnnotations(ctx context.Context, podUID string) (map[string]string, error)
+}
+
+// WithMode sets the mode used for pods which do not select one with
+// ModeAnnotation, ModeFreeze if not set
+func WithMode(mode string) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.mode = mode
+	}
+}
+
+// podMode returns the mode for the pod
+func (c *ContainerRuntimeImpl) podMode(ctx context.Context, podName string) (string, error) {
+	mode := c.mode
+	if mode == "" {
+		mode = ModeFreeze
+	}
+	if a, ok := c.cri.(PodAnnotator); ok {
+		annotations, err := a.PodAnnotations(ctx, podName)
+		if err != nil {
+			return "", err
+		}
+		if m := annotations[ModeAnnotation]; m != "" {
+			mode = m
+		}
+	}
+
+	switch mode {
+	case ModeFreeze:
+	case ModeThrottle:
+		if _, ok := c.cri.(Throttler); !ok {
+			return "", fmt.Errorf("runtime cannot throttle pod %s", podName)
+		}
+	default:
+		return "", fmt.Errorf("unrecognised mode %q for pod %s", mode, podName)
+	}
+	return mode, nil
+}
@@ -0,0 +1,99 @@
+package freeze
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// throttlingCRI is a recordingCRI which can throttle containers and whose
+// pod carries the given annotations
+type throttlingCRI struct {
+	*recordingCRI
+	annotations map[string]string
+}
+
+func (c throttlingCRI) Throttle(ctx context.Context, container string) error {
+	c.record("throttle")
+	return nil
+}
+
+func (c throttlingCRI) Unthrottle(ctx context.Context, container string) error {
+	c.record("unthrottle")
+	return nil
+}
+
+func (c throttlingCRI) PodAnnotations(ctx context.Context, podUID string) (map[string]string, error) {
+	return c.annotations, nil
+}
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		annotations map[string]string
+		wantErr     bool
+		wantCalls   []string
+	}{{
+		name:      "freeze by default",
+		wantCalls: []string{"pause", "resume"},
+	}, {
+		name:      "throttle globally",
+		mode:      ModeThrottle,
+		wantCalls: []string{"throttle", "unthrottle"},
+	}, {
+		name:        "throttle one pod",
+		annotations: map[string]string{ModeAnnotation: ModeThrottle},
+		wantCalls:   []string{"throttle", "unthrottle"},
+	}, {
+		name:        "freeze one pod",
+		mode:        ModeThrottle,
+		annotations: map[string]string{ModeAnnotation: ModeFreeze},
+		wantCalls:   []string{"pause", "resume"},
+	}, {
+		name:        "unknown mode",
+		annotations: map[string]string{ModeAnnotation: "sleep"},
+		wantErr:     true,
+	}}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			fake := throttlingCRI{recordingCRI: newRecordingCRI(), annotations: c.annotations}
+			impl := &ContainerRuntimeImpl{cri: fake}
+			WithMode(c.mode)(impl)
+
+			err := impl.Freeze(context.Background(), "pod")
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %v but got %v", c.wantErr, err)
+			}
+			if c.wantErr {
+				if calls := fake.getCalls(); len(calls) != 0 {
+					t.Errorf("expected no runtime calls but got %v", calls)
+				}
+				return
+			}
+
+			wantMode := ModeFreeze
+			if c.wantCalls[0] == "throttle" {
+				wantMode = ModeThrottle
+			}
+			if frozen := impl.Frozen(); len(frozen) != 1 || frozen[0].Mode != wantMode {
+				t.Errorf("expected the pod to be in mode %s but got %+v", wantMode, frozen)
+			}
+
+			if err := impl.Thaw(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected thaw to succeed but failed: %v", err)
+			}
+			if calls := fake.getCalls(); !reflect.DeepEqual(calls, c.wantCalls) {
+				t.Errorf("expected calls %v but got %v", c.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestThrottleNotSupported(t *testing.T) {
+	impl := &ContainerRuntimeImpl{cri: newRecordingCRI(), mode: ModeThrottle}
+	if err := impl.Freeze(context.Background(), "pod"); err == nil {
+		t.Error("expected throttling with a runtime which cannot throttle to fail")
+	}
+}
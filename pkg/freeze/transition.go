@@ -0,0 +1,62 @@
+package freeze
+
+import "time"
+
+// Transition describes a freeze or thaw which paused or resumed containers
+// of a pod, or which failed
+type Transition struct {
+	PodUID string
+	// Action is ActionFreeze or ActionThaw
+	Action string
+	// Started is when the operation started, Duration is how long it took
+	Started  time.Time
+	Duration time.Duration
+	// FrozenFor is how long the pod had been frozen when it was thawed
+	FrozenFor time.Duration
+	// Err is set if the operation failed
+	Err error
+}
+
+// TransitionFunc is called after every transition. It is called on the
+// goroutine which made the transition, so it must not block.
+type TransitionFunc func(Transition)
+
+// WithTransitionFunc calls fn after every transition
+func WithTransitionFunc(fn TransitionFunc) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.onTransition = fn
+	}
+}
+
+// ChangeFunc is called with the UID of a pod whose state changed other than
+// by a transition: its paused containers were resumed by someone else or
+// exited, or its delayed pause was cancelled. Like a TransitionFunc it must
+// not block.
+type ChangeFunc func(podUID string)
+
+// WithChangeFunc calls fn after every change of a pod which is not a
+// transition
+func WithChangeFunc(fn ChangeFunc) Option {
+	return func(c *ContainerRuntimeImpl) {
+		c.onChange = fn
+	}
+}
+
+// changed reports a change of the pod which is not a transition
+func (c *ContainerRuntimeImpl) changed(podUID string) {
+	if c.onChange != nil {
+		c.onChange(podUID)
+	}
+}
+
+// finished records the outcome of an operation. changed reports whether any
+// container was paused or resumed, operations which neither changed the pod
+// nor failed are not transitions.
+func (c *ContainerRuntimeImpl) finished(t Transition, changed bool) {
+	c.recordError(t.PodUID, t.Action, t.Err)
+	if c.onTransition == nil || (!changed && t.Err == nil) {
+		return
+	}
+	t.Duration = c.getClock().Since(t.Started)
+	c.onTransition(t)
+}
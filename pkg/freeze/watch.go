@@ -0,0 +1,105 @@
+package freeze
+
+import (
+	"context"
+	"time"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// EventWatcher is implemented by runtimes which report the changes made to
+// containers, by the freezer or anyone else
+type EventWatcher interface {
+	// WatchEvents sets fn to be called for every change
+	WatchEvents(fn func(common.ContainerEvent))
+}
+
+// containerChanged brings the state of the freezer in line with a change made
+// to a container behind its back, such as a frozen container resumed with ctr
+// or restarted by the kubelet. Changes to pods the freezer is working on are
+// its own and ignored.
+func (c *ContainerRuntimeImpl) containerChanged(ev common.ContainerEvent) {
+	c.mu.Lock()
+	podName, st := c.podOfLocked(ev.ContainerID)
+	if st != nil && st.busy != nil {
+		c.mu.Unlock()
+		return
+	}
+	var latest uint64
+	var mode string
+	if st != nil {
+		latest, mode = st.latest, st.mode
+	}
+	c.mu.Unlock()
+
+	switch {
+	case ev.State == common.StatePaused && st == nil:
+		if !c.confirmed(ev) {
+			return
+		}
+		recordStateMismatch(mismatchPausedExternally)
+		c.getLogger().Warnw("container paused behind the freezer's back", "container", ev.ContainerID)
+	case ev.State == common.StateRunning && st != nil && mode == ModeFreeze:
+		if !c.confirmed(ev) {
+			return
+		}
+		recordStateMismatch(mismatchResumedExternally)
+		c.getLogger().Warnw("frozen container resumed behind the freezer's back", "pod", podName, "container", ev.ContainerID)
+		if c.forgetContainer(podName, st, latest, ev.ContainerID) {
+			c.changed(podName)
+		}
+	case ev.State == common.StateStopped && st != nil:
+		recordStateMismatch(mismatchExited)
+		c.getLogger().Warnw("frozen container exited", "pod", podName, "container", ev.ContainerID)
+		if c.forgetContainer(podName, st, latest, ev.ContainerID) {
+			c.changed(podName)
+		}
+	}
+}
+
+// podOfLocked returns the pod the freezer paused the container of, or is
+// pausing it right now. c.mu must be held.
+func (c *ContainerRuntimeImpl) podOfLocked(ctr string) (string, *podState) {
+	for name, st := range c.pods {
+		if st.stopping == ctr || contains(st.paused, ctr) {
+			return name, st
+		}
+	}
+	return "", nil
+}
+
+// confirmed reports whether the container is still in the state of the event,
+// which may be an old one about a change the freezer made before
+func (c *ContainerRuntimeImpl) confirmed(ev common.ContainerEvent) bool {
+	var state common.ContainerState
+	var reported bool
+	_, err := call(context.Background(), c.timeouts.List, func(ctx context.Context) (err error) {
+		state, reported, err = c.status(ctx, ev.ContainerID)
+		return err
+	})
+	return err == nil && (!reported || state == ev.State)
+}
+
+// forgetContainer drops a container which is no longer paused from the pod,
+// unless the freezer touched the pod meanwhile. It returns whether it did.
+func (c *ContainerRuntimeImpl) forgetContainer(podName string, st *podState, latest uint64, ctr string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pods[podName] != st || st.busy != nil || st.latest != latest {
+		return false
+	}
+	st.paused = remove(st.paused, ctr)
+	if contains(st.checkpointed, ctr) {
+		st.checkpointed = remove(st.checkpointed, ctr)
+		c.saveCheckpointedLocked(podName, st)
+	}
+	if len(st.paused) == 0 {
+		cancelCheckpointLocked(st)
+		cancelReclaimLocked(st)
+		st.frozenAt = time.Time{}
+		st.reclaimedBytes = 0
+		st.mode = ""
+		c.forgetLocked(podName, st)
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+package freeze
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+
+	"knative.dev/container-freezer/pkg/freeze/common"
+)
+
+// watchingCRI is a statusCRI which hands its event function to the test
+type watchingCRI struct {
+	*statusCRI
+	onEvent func(common.ContainerEvent)
+}
+
+func (w *watchingCRI) WatchEvents(fn func(common.ContainerEvent)) {
+	w.onEvent = fn
+}
+
+// watchedCRI is the backend the test-watching runtime type creates
+var watchedCRI = &watchingCRI{statusCRI: newStatusCRI()}
+
+func init() {
+	Register("test-watching", func(opts BackendOptions) (CRI, error) {
+		return watchedCRI, nil
+	}, Capabilities{Status: true})
+}
+
+func mismatchCount(t *testing.T, reason string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(stateMismatchesM.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == reasonKey && tag.Value == reason {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	return 0
+}
+
+func TestContainerChanged(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        common.ContainerEvent
+		state        common.ContainerState
+		checkpointed bool
+		busy         bool
+		wantFrozen   bool
+		wantReason   string
+	}{{
+		name:       "paused by the freezer",
+		event:      common.ContainerEvent{ContainerID: "ctr", State: common.StatePaused},
+		state:      common.StatePaused,
+		wantFrozen: true,
+	}, {
+		name:       "resumed behind the freezer's back",
+		event:      common.ContainerEvent{ContainerID: "ctr", State: common.StateRunning},
+		state:      common.StateRunning,
+		wantReason: mismatchResumedExternally,
+	}, {
+		name:       "resumed before the freezer paused it again",
+		event:      common.ContainerEvent{ContainerID: "ctr", State: common.StateRunning},
+		state:      common.StatePaused,
+		wantFrozen: true,
+	}, {
+		name:       "exited",
+		event:      common.ContainerEvent{ContainerID: "ctr", State: common.StateStopped},
+		state:      common.StateStopped,
+		wantReason: mismatchExited,
+	}, {
+		// Checkpointed containers stay paused, one which stopped exited
+		name:         "checkpointed and exited",
+		event:        common.ContainerEvent{ContainerID: "ctr", State: common.StateStopped},
+		state:        common.StateStopped,
+		checkpointed: true,
+		wantReason:   mismatchExited,
+	}, {
+		name:       "resumed while the freezer works on the pod",
+		event:      common.ContainerEvent{ContainerID: "ctr", State: common.StateRunning},
+		state:      common.StateRunning,
+		busy:       true,
+		wantFrozen: true,
+	}, {
+		name:       "other container paused behind the freezer's back",
+		event:      common.ContainerEvent{ContainerID: "other", State: common.StatePaused},
+		state:      common.StatePaused,
+		wantFrozen: true,
+		wantReason: mismatchPausedExternally,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newStatusCRI()
+			var changed []string
+			impl := &ContainerRuntimeImpl{cri: fake}
+			WithChangeFunc(func(podUID string) { changed = append(changed, podUID) })(impl)
+			if err := impl.Freeze(context.Background(), "pod"); err != nil {
+				t.Fatalf("expected freeze to succeed but got %v", err)
+			}
+			impl.mu.Lock()
+			st := impl.pods["pod"]
+			if test.checkpointed {
+				st.checkpointed = []string{"ctr"}
+			}
+			if test.busy {
+				st.busy = make(chan struct{})
+			}
+			impl.mu.Unlock()
+			var before int64
+			if test.wantReason != "" {
+				before = mismatchCount(t, test.wantReason)
+			}
+
+			fake.set(test.state)
+			impl.containerChanged(test.event)
+
+			if frozen := impl.Frozen(); (len(frozen) == 1) != test.wantFrozen {
+				t.Errorf("expected frozen %v but got %+v", test.wantFrozen, frozen)
+			}
+			// Watchers hear about pods which are no longer frozen
+			if (len(changed) == 1) == test.wantFrozen {
+				t.Errorf("expected a change %v but got %v", !test.wantFrozen, changed)
+			}
+			if test.wantReason != "" {
+				if got := mismatchCount(t, test.wantReason) - before; got != 1 {
+					t.Errorf("expected a %s mismatch to be recorded but got %d", test.wantReason, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCRIProviderWatchesEvents(t *testing.T) {
+	impl, err := NewCRIProvider("test-watching")
+	if err != nil {
+		t.Fatalf("expected provider to be created but got %v", err)
+	}
+	if watchedCRI.onEvent == nil {
+		t.Fatal("expected the provider to watch the events of the backend")
+	}
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+
+	watchedCRI.set(common.StateRunning)
+	watchedCRI.onEvent(common.ContainerEvent{ContainerID: "ctr", State: common.StateRunning})
+	if frozen := impl.Frozen(); len(frozen) != 0 {
+		t.Errorf("expected the resumed container to be forgotten but got %+v", frozen)
+	}
+}
+
+// eventDuringVerifyCRI delivers the event of the pause the first time the
+// freezer checks that the container is paused, like containerd does while the
+// freezer verifies the pause
+type eventDuringVerifyCRI struct {
+	*statusCRI
+	impl      *ContainerRuntimeImpl
+	delivered bool
+}
+
+func (e *eventDuringVerifyCRI) Status(ctx context.Context, container string) (common.ContainerState, error) {
+	if !e.delivered {
+		e.delivered = true
+		e.impl.containerChanged(common.ContainerEvent{ContainerID: container, State: common.StatePaused})
+	}
+	return e.statusCRI.Status(ctx, container)
+}
+
+func TestOwnPauseEventDuringVerification(t *testing.T) {
+	fake := &eventDuringVerifyCRI{statusCRI: newStatusCRI()}
+	impl := &ContainerRuntimeImpl{cri: fake}
+	fake.impl = impl
+	WithFreezeVerification(time.Second, nil)(impl)
+	before := mismatchCount(t, mismatchPausedExternally)
+
+	if err := impl.Freeze(context.Background(), "pod"); err != nil {
+		t.Fatalf("expected freeze to succeed but got %v", err)
+	}
+	if !fake.delivered {
+		t.Fatal("expected the event to be delivered during verification")
+	}
+	if got := mismatchCount(t, mismatchPausedExternally) - before; got != 0 {
+		t.Errorf("expected the freezer's own pause not to be reported as a mismatch but got %d", got)
+	}
+}